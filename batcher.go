@@ -0,0 +1,373 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when a log is dropped because the queue of a
+// batching Drinker is full
+var ErrQueueFull = errors.New("Queue full")
+
+// ErrDrinkerClosed is returned when a log is drunk by a closed Drinker
+var ErrDrinkerClosed = errors.New("Drinker closed")
+
+// BatchConfig is the configuration of the batching and delivery of the
+// Drinkers that ship logs in batches
+type BatchConfig struct {
+	// Size is the number of entries sent together
+	Size int
+	// Bytes is the size in bytes at which entries are sent before reaching
+	// Size, unlimited if zero
+	Bytes int
+	// Interval is how often entries are sent when fewer are buffered.
+	// They are only sent by Flush and Close if zero.
+	Interval time.Duration
+
+	// QueueSize is the number of entries waiting to be sent. Entries drunk
+	// when it is full are dropped, or spooled if SpoolDir is set.
+	QueueSize int
+	// SpoolDir is the directory entries are spooled to when the queue is full
+	// or they could not be sent, to be sent again later
+	SpoolDir string
+
+	// MaxRetries is the number of times failed entries are sent again
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the wait before sending again, which
+	// doubles with each failure
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// ErrorHandler is called with errors from sending entries in the
+	// background. Errors are ignored if nil.
+	ErrorHandler func(error)
+}
+
+// DefaultBatchConfig creates a default BatchConfig
+func DefaultBatchConfig() *BatchConfig {
+	return &BatchConfig{
+		Size:       500,
+		Bytes:      1 << 20,
+		Interval:   time.Second,
+		QueueSize:  10000,
+		MaxRetries: 5,
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+	}
+}
+
+// batchItem is an encoded entry, along with the key it is grouped by
+type batchItem struct {
+	key  string
+	data []byte
+}
+
+// batchSender sends items, returning those that failed and should be sent again
+type batchSender func(items []batchItem) ([]batchItem, error)
+
+// batcher queues items and sends them in batches from its own goroutine,
+// retrying failures with backoff
+type batcher struct {
+	config BatchConfig
+	send   batchSender
+
+	queue   chan batchItem
+	flushes chan chan error
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	lock   sync.Mutex
+	closed bool
+	spool  string
+}
+
+// newBatcher creates a batcher and starts its goroutine
+func newBatcher(config BatchConfig, send batchSender) (*batcher, error) {
+	if config.Size < 1 {
+		config.Size = 1
+	}
+
+	if config.QueueSize < 1 {
+		config.QueueSize = config.Size
+	}
+
+	btchr := &batcher{
+		config:  config,
+		send:    send,
+		queue:   make(chan batchItem, config.QueueSize),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+
+	if config.SpoolDir != "" {
+		if err := os.MkdirAll(config.SpoolDir, 0o700); err != nil {
+			return nil, err
+		}
+		btchr.spool = filepath.Join(config.SpoolDir, "lager.spool")
+	}
+
+	btchr.wg.Add(1)
+	go btchr.run()
+	return btchr, nil
+}
+
+// add queues item, spooling or dropping it if the queue is full
+func (btchr *batcher) add(item batchItem) error {
+	btchr.lock.Lock()
+	defer btchr.lock.Unlock()
+
+	if btchr.closed {
+		return ErrDrinkerClosed
+	}
+
+	select {
+	case btchr.queue <- item:
+		return nil
+	default:
+	}
+
+	if btchr.spool == "" {
+		return ErrQueueFull
+	}
+	return btchr.writeSpool([]batchItem{item})
+}
+
+// flush sends the queued items and any spooled ones
+func (btchr *batcher) flush() error {
+	done := make(chan error)
+
+	select {
+	case btchr.flushes <- done:
+		return <-done
+	case <-btchr.done:
+		return ErrDrinkerClosed
+	}
+}
+
+// close sends the queued items and stops the batcher
+func (btchr *batcher) close() error {
+	btchr.lock.Lock()
+	if btchr.closed {
+		btchr.lock.Unlock()
+		return nil
+	}
+	btchr.closed = true
+	btchr.lock.Unlock()
+
+	err := btchr.flush()
+	close(btchr.done)
+	btchr.wg.Wait()
+	return err
+}
+
+func (btchr *batcher) run() {
+	defer btchr.wg.Done()
+
+	var tick <-chan time.Time
+	if btchr.config.Interval > 0 {
+		ticker := time.NewTicker(btchr.config.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var batch []batchItem
+	var size int
+
+	// sendBatch sends the batch, returning any error
+	sendBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		err := btchr.ship(batch)
+		batch, size = nil, 0
+		return err
+	}
+
+	// addItem adds item to the batch, sending the batch if it is full
+	addItem := func(item batchItem) error {
+		batch = append(batch, item)
+		size += len(item.data)
+
+		if len(batch) < btchr.config.Size && (btchr.config.Bytes <= 0 || size < btchr.config.Bytes) {
+			return nil
+		}
+		return sendBatch()
+	}
+
+	for {
+		select {
+		case item := <-btchr.queue:
+			btchr.handleError(addItem(item))
+		case <-tick:
+			err := sendBatch()
+			if err == nil {
+				err = btchr.replaySpool()
+			}
+			btchr.handleError(err)
+		case done := <-btchr.flushes:
+			var errs []error
+			for drained := false; !drained; {
+				select {
+				case item := <-btchr.queue:
+					errs = append(errs, addItem(item))
+				default:
+					drained = true
+				}
+			}
+			errs = append(errs, sendBatch(), btchr.replaySpool())
+			done <- errors.Join(errs...)
+		case <-btchr.done:
+			return
+		}
+	}
+}
+
+// ship sends items, sending those that failed again with backoff. Items that
+// still fail are spooled, or dropped if there is no spool.
+func (btchr *batcher) ship(items []batchItem) error {
+	backoff := btchr.config.MinBackoff
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		items, err = btchr.send(items)
+		if len(items) == 0 || attempt == btchr.config.MaxRetries {
+			break
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > btchr.config.MaxBackoff {
+			backoff = btchr.config.MaxBackoff
+		}
+	}
+
+	if len(items) == 0 {
+		return err
+	}
+
+	if btchr.spool != "" {
+		btchr.lock.Lock()
+		defer btchr.lock.Unlock()
+
+		if spoolErr := btchr.writeSpool(items); spoolErr != nil {
+			return fmt.Errorf("dropped %d logs: %v", len(items), spoolErr)
+		}
+		return err
+	}
+
+	return fmt.Errorf("dropped %d logs: %v", len(items), err)
+}
+
+func (btchr *batcher) handleError(err error) {
+	if err != nil && btchr.config.ErrorHandler != nil {
+		btchr.config.ErrorHandler(err)
+	}
+}
+
+// writeSpool appends items to the spool, each as its length prefixed key and
+// data. btchr.lock must be held.
+func (btchr *batcher) writeSpool(items []batchItem) error {
+	f, err := os.OpenFile(btchr.spool, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+	for _, item := range items {
+		b = binary.AppendUvarint(b, uint64(len(item.key)))
+		b = append(b, item.key...)
+		b = binary.AppendUvarint(b, uint64(len(item.data)))
+		b = append(b, item.data...)
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replaySpool sends the spooled items in batches
+func (btchr *batcher) replaySpool() error {
+	if btchr.spool == "" {
+		return nil
+	}
+
+	btchr.lock.Lock()
+	items, err := btchr.readSpool()
+	btchr.lock.Unlock()
+
+	errs := []error{err}
+	for start := 0; start < len(items); start += btchr.config.Size {
+		end := start + btchr.config.Size
+		if end > len(items) {
+			end = len(items)
+		}
+		errs = append(errs, btchr.ship(items[start:end]))
+	}
+	return errors.Join(errs...)
+}
+
+// readSpool reads and removes the spooled items. Items after any that cannot
+// be read are lost. btchr.lock must be held.
+func (btchr *batcher) readSpool() ([]batchItem, error) {
+	f, err := os.Open(btchr.spool)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []batchItem
+	r := bufio.NewReader(f)
+	for {
+		var key, data []byte
+		if key, err = readSpoolBytes(r); err == nil {
+			data, err = readSpoolBytes(r)
+		}
+
+		if err != nil {
+			break
+		}
+		items = append(items, batchItem{key: string(key), data: data})
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+	return items, errors.Join(err, os.Remove(btchr.spool))
+}
+
+// readSpoolBytes reads length prefixed bytes
+func readSpoolBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, n)
+	_, err = io.ReadFull(r, data)
+	return data, err
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColorMode represents when ConsoleDrinker uses colors
+type ColorMode uint8
+
+const (
+	// ColorAuto uses colors when the output is a terminal and NO_COLOR is not set
+	ColorAuto ColorMode = iota
+	// ColorAlways always uses colors
+	ColorAlways
+	// ColorNever never uses colors
+	ColorNever
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorDim   = "\x1b[2m"
+)
+
+var levelColors = map[Level]string{
+	Trace: "\x1b[90m",
+	Debug: "\x1b[36m",
+	Info:  "\x1b[32m",
+	Warn:  "\x1b[33m",
+	Error: "\x1b[31m",
+}
+
+// ConsoleConfig is the configuration for ConsoleDrinker
+type ConsoleConfig struct {
+	Output io.Writer
+	Color  ColorMode
+
+	// TimeLayout is the layout times are logged with
+	TimeLayout string
+	// RelativeTime logs times as the time since the drinker was created
+	RelativeTime bool
+	// MessageWidth is the width messages are padded to, aligning the fields after them
+	MessageWidth int
+	// AllowControlChars logs control characters in messages and values as is,
+	// instead of escaping them. Only use it for trusted input.
+	AllowControlChars bool
+}
+
+// DefaultConsoleConfig creates a default ConsoleConfig
+func DefaultConsoleConfig() *ConsoleConfig {
+	return &ConsoleConfig{
+		Output:       os.Stderr,
+		TimeLayout:   "15:04:05.000",
+		MessageWidth: 40,
+	}
+}
+
+// ConsoleDrinker is a Drinker that logs in a human friendly format for development
+type ConsoleDrinker struct {
+	output io.Writer
+	color  bool
+
+	timeLayout   string
+	relativeTime bool
+	messageWidth int
+	raw          bool
+	start        time.Time
+}
+
+// NewConsoleDrinker creates a new Console Drinker with the default configuration
+func NewConsoleDrinker(output io.Writer) Drinker {
+	config := DefaultConsoleConfig()
+	config.Output = output
+	return NewConsoleDrinkerWithConfig(config)
+}
+
+// NewConsoleDrinkerWithConfig creates a new Console Drinker
+func NewConsoleDrinkerWithConfig(config *ConsoleConfig) Drinker {
+	if config == nil {
+		config = DefaultConsoleConfig()
+	}
+
+	color := config.Color == ColorAlways
+	if config.Color == ColorAuto {
+		color = isTerminal(config.Output) && os.Getenv("NO_COLOR") == ""
+	}
+
+	return &ConsoleDrinker{
+		output:       config.Output,
+		color:        color,
+		timeLayout:   config.TimeLayout,
+		relativeTime: config.RelativeTime,
+		messageWidth: config.MessageWidth,
+		raw:          config.AllowControlChars,
+		start:        time.Now(),
+	}
+}
+
+// isTerminal returns whether w is a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Drink drinks logs, using the default keys for the standard values. Textual
+// stacktraces are indented below the log, as stacks are.
+func (drkr *ConsoleDrinker) Drink(v map[string]interface{}) error {
+	e := entryFromMap(v)
+
+	key := defaultFormat.Keys.Stacktrace
+	stack, ok := v[key].(string)
+	if !ok {
+		return drkr.DrinkEntry(e)
+	}
+
+	e.Fields = e.Fields.without(key)
+	return drkr.drink(e, stack)
+}
+
+// DrinkEntry drinks logs, reusing the cached encoding of the entry's fields
+func (drkr *ConsoleDrinker) DrinkEntry(e *Entry) error {
+	return drkr.drink(e, "")
+}
+
+// drink writes e, followed by its stack or else stackText
+func (drkr *ConsoleDrinker) drink(e *Entry, stackText string) error {
+	format := e.Format
+	if format == nil {
+		format = defaultFormat
+	}
+
+	var when string
+	if drkr.relativeTime {
+		when = fmt.Sprintf("+%.3fs", e.Time.Sub(drkr.start).Seconds())
+	} else {
+		when = e.Time.Format(drkr.timeLayout)
+	}
+
+	b := new(bytes.Buffer)
+	drkr.appendHeader(b, when, e.Level, e.Message)
+
+	if e.File != "" {
+		drkr.appendField(b, format.Keys.File, e.File)
+	}
+	if e.Func != "" {
+		drkr.appendField(b, format.Keys.Func, e.Func)
+	}
+	b.Write(e.Fields.Encoded(drkr, drkr.encodeFields))
+	b.WriteByte('\n')
+
+	if len(e.Stack) > 0 {
+		drkr.appendStack(b, e.Stack)
+	} else if stackText != "" {
+		drkr.appendStackText(b, stackText)
+	}
+
+	_, err := drkr.output.Write(b.Bytes())
+	return err
+}
+
+// appendHeader appends the time, level badge and padded message
+func (drkr *ConsoleDrinker) appendHeader(b *bytes.Buffer, when string, lvl Level, msg string) {
+	drkr.appendColored(b, colorDim, when)
+	b.WriteByte(' ')
+
+	badge := fmt.Sprintf("%-5s", strings.ToUpper(lvl.String()))
+	if len(badge) > 5 {
+		badge = badge[:5]
+	}
+	drkr.appendColored(b, colorBold+levelColors[lvl], badge)
+	b.WriteByte(' ')
+
+	if !drkr.raw {
+		msg = escapeControl(msg)
+	}
+
+	b.WriteString(msg)
+	if pad := drkr.messageWidth - len(msg); pad > 0 {
+		b.WriteString(strings.Repeat(" ", pad))
+	}
+}
+
+// encodeFields encodes fields as dimmed key=value pairs
+func (drkr *ConsoleDrinker) encodeFields(fields []Field) []byte {
+	b := new(bytes.Buffer)
+	for _, field := range fields {
+		drkr.appendField(b, field.Key, field.Value)
+	}
+	return b.Bytes()
+}
+
+func (drkr *ConsoleDrinker) appendField(b *bytes.Buffer, key string, value interface{}) {
+	if !drkr.raw {
+		key = escapeControl(key)
+	}
+
+	b.WriteByte(' ')
+	drkr.appendColored(b, colorDim, key+"=")
+
+	str := fmt.Sprint(value)
+	switch {
+	case str == "" || strings.ContainsAny(str, " \t\"="):
+		if drkr.raw {
+			str = `"` + str + `"`
+		} else {
+			str = strconv.Quote(str)
+		}
+	case !drkr.raw:
+		str = escapeControl(str)
+	}
+	b.WriteString(str)
+}
+
+// appendStack appends stack with a frame on each line
+func (drkr *ConsoleDrinker) appendStack(b *bytes.Buffer, stack Stack) {
+	for _, frame := range stack {
+		b.WriteString("    ")
+		drkr.appendColored(b, levelColors[Error], frame.Func)
+		b.WriteString("\n        ")
+		drkr.appendColored(b, colorDim, fmt.Sprintf("%s:%d", frame.File, frame.Line))
+		b.WriteByte('\n')
+	}
+}
+
+// appendStackText appends a textual stacktrace indented
+func (drkr *ConsoleDrinker) appendStackText(b *bytes.Buffer, stack string) {
+	for _, line := range strings.Split(strings.TrimRight(stack, "\n"), "\n") {
+		b.WriteString("    ")
+		drkr.appendColored(b, colorDim, line)
+		b.WriteByte('\n')
+	}
+}
+
+func (drkr *ConsoleDrinker) appendColored(b *bytes.Buffer, color, text string) {
+	if !drkr.color || color == "" {
+		b.WriteString(text)
+		return
+	}
+
+	b.WriteString(color)
+	b.WriteString(text)
+	b.WriteString(colorReset)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsoleDrinker(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	config := DefaultConsoleConfig()
+	config.Output = buf
+	config.MessageWidth = 10
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).All(),
+		Drinker: NewConsoleDrinkerWithConfig(config),
+		Clock:   fixedClock(time.Date(2015, 10, 21, 16, 29, 0, 0, time.UTC)),
+	})
+
+	logger.Set("user", "marty mcfly").WithError(errors.New("late")).Warnf("hello")
+
+	expected := "16:29:00.000 WARN  hello      user=\"marty mcfly\" error=late\n"
+	if buf.String() != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, buf.String())
+	}
+}
+
+func TestConsoleDrinkerColor(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	config := DefaultConsoleConfig()
+	config.Output = buf
+	config.Color = ColorAlways
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:      new(Levels).Set(Error),
+		Drinker:     NewConsoleDrinkerWithConfig(config),
+		Stacktraces: true,
+	})
+
+	logger.Set("a", "one").Errorf("failed")
+
+	actual := buf.String()
+	for _, expected := range []string{
+		colorBold + levelColors[Error] + "ERROR" + colorReset,
+		colorDim + "a=" + colorReset + "one",
+		"\n    " + levelColors[Error] + "github.com/doubledutch/lager.TestConsoleDrinkerColor" + colorReset + "\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Fatalf("expected %q to contain %q", actual, expected)
+		}
+	}
+}
+
+func TestConsoleDrinkerAutoColor(t *testing.T) {
+	drinker := NewConsoleDrinker(new(bytes.Buffer)).(*ConsoleDrinker)
+	if drinker.color {
+		t.Fatal("expected no color for a buffer")
+	}
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	os.Setenv("NO_COLOR", "1")
+	defer os.Unsetenv("NO_COLOR")
+
+	drinker = NewConsoleDrinker(f).(*ConsoleDrinker)
+	if drinker.color {
+		t.Fatal("expected no color with NO_COLOR set")
+	}
+}
+
+func TestConsoleDrinkerRelativeTime(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	config := DefaultConsoleConfig()
+	config.Output = buf
+	config.RelativeTime = true
+
+	drinker := NewConsoleDrinkerWithConfig(config).(*ConsoleDrinker)
+	drinker.DrinkEntry(&Entry{
+		Time:    drinker.start.Add(1500 * time.Millisecond),
+		Level:   Info,
+		Message: "later",
+	})
+
+	if !strings.HasPrefix(buf.String(), "+1.500s INFO  later") {
+		t.Fatalf("expected relative time, got '%s'", buf.String())
+	}
+}
+
+func TestConsoleDrinkerMap(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	config := DefaultConsoleConfig()
+	config.Output = buf
+	config.MessageWidth = 10
+
+	drinker := NewConsoleDrinkerWithConfig(config)
+	drinker.Drink(map[string]interface{}{
+		"time":       "2015-10-21T16:29:00Z",
+		"level":      "Warn",
+		"msg":        "hello",
+		"file":       "main.go:42",
+		"func":       "main.main",
+		"user":       "marty",
+		"stacktrace": "main.main()\n\tmain.go:42\n",
+	})
+
+	expected := "16:29:00.000 WARN  hello      file=main.go:42 func=main.main user=marty\n" +
+		"    main.main()\n    \tmain.go:42\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+type fixedClock time.Time
+
+func (clock fixedClock) Now() time.Time {
+	return time.Time(clock)
+}
@@ -16,8 +16,7 @@ package lager
 import (
 	"fmt"
 	"os"
-	"runtime/debug"
-	"time"
+	"sort"
 )
 
 // ContextLager is a Lager that adds context to logs with key value pairs
@@ -25,17 +24,57 @@ type ContextLager interface {
 	Lager
 	With(map[string]string) ContextLager
 	WithError(error) ContextLager
+	WithNamedError(key string, err error) ContextLager
+	WithErrors(errs ...error) ContextLager
 	Set(key, value string) ContextLager
+	SetLazy(key string, value Valuer) ContextLager
 	Child() ContextLager
+	AddCallerSkip(skip int) ContextLager
+	Recover(opts *RecoverOptions)
+	TraceFn(fn LogFunc)
+	DebugFn(fn LogFunc)
+	InfoFn(fn LogFunc)
+	WarnFn(fn LogFunc)
+	ErrorFn(fn LogFunc)
 }
 
+// LogFunc produces the message and context fields of a log. It is only called
+// if the level of the log is enabled, so expensive logs cost nothing otherwise.
+type LogFunc func() (msg string, fields map[string]interface{})
+
 // ContextConfig is defines the configuration for ContextLager.
 type ContextConfig struct {
 	Levels      *Levels
 	Drinker     Drinker
 	Values      map[string]string
 	Stacktraces bool
-	FileType    FileType
+	// StacktraceLevels are the levels that capture stacktraces when Stacktraces
+	// is on, such as Warn|Error. Only Error captures them if zero.
+	StacktraceLevels Level
+	// StacktraceDepth is the maximum number of frames captured, unlimited if zero
+	StacktraceDepth int
+
+	FileType   FileType
+	FieldOrder FieldOrder
+	KeyPolicy  KeyPolicy
+
+	// FuncName includes the name of the function that logged
+	FuncName bool
+
+	// CallerSkip is the number of extra frames to skip when finding the file
+	// and function that logged, for lagers wrapped by helper functions.
+	CallerSkip int
+
+	// Format defines the keys and formatting of the standard values of a log.
+	// DefaultFormat is used if nil, and for any keys or time layout left empty.
+	Format *Format
+
+	// Clock tells the time of logs. The system clock is used if nil.
+	Clock Clock
+
+	// ErrorHandler is called with errors from logging, such as a Drinker
+	// failing or a key rejected by RejectReserved. Errors are ignored if nil.
+	ErrorHandler func(error)
 }
 
 // DefaultContextConfig creates a default ContextConfig
@@ -52,37 +91,51 @@ type contextLager struct {
 
 	drinker Drinker
 
-	values      map[string]string
+	fields      *Fields
 	stacktraces bool
+	stackLevels Level
+	stackDepth  int
 	fileType    FileType
+	funcName    bool
+	callerSkip  int
+	fieldOrder  FieldOrder
+	keyPolicy   KeyPolicy
+	format      *Format
+	clock       Clock
+
+	errorHandler func(error)
 }
 
 // NewContextLager creates a JSONLager
 func NewContextLager(config *ContextConfig) ContextLager {
-	values := make(map[string]string)
-
 	if config == nil {
 		config = DefaultContextConfig()
 	}
 
-	if config.Values == nil {
-		config.Values = make(map[string]string)
+	logger := &contextLager{
+		drinker:      config.Drinker,
+		stacktraces:  config.Stacktraces,
+		stackLevels:  config.StacktraceLevels,
+		stackDepth:   config.StacktraceDepth,
+		fileType:     config.FileType,
+		funcName:     config.FuncName,
+		callerSkip:   config.CallerSkip,
+		fieldOrder:   config.FieldOrder,
+		keyPolicy:    config.KeyPolicy,
+		format:       config.Format.withDefaults(),
+		clock:        config.Clock,
+		errorHandler: config.ErrorHandler,
 	}
 
-	//copy all keys and values into allValues
-	for k, v := range config.Values {
-		if _, ok := values[k]; !ok {
-			values[k] = v
-		}
+	if logger.stackLevels == 0 {
+		logger.stackLevels = Error
 	}
 
-	logger := &contextLager{
-		drinker:     config.Drinker,
-		values:      values,
-		stacktraces: config.Stacktraces,
-		fileType:    config.FileType,
+	if logger.clock == nil {
+		logger.clock = systemClock{}
 	}
 
+	logger.set(newFields(config.Values).List()...)
 	logger.Lager = newLager(logger, config.Levels)
 	return logger
 }
@@ -92,10 +145,8 @@ func (lgr *contextLager) With(fields map[string]string) ContextLager {
 		return lgr
 	}
 
-	clgr := lgr.Child()
-	for key, value := range fields {
-		clgr.Set(key, value)
-	}
+	clgr := lgr.child()
+	clgr.set(newFields(fields).List()...)
 	return clgr
 }
 
@@ -104,55 +155,247 @@ func (lgr *contextLager) WithError(err error) ContextLager {
 		return lgr
 	}
 
-	clgr := lgr.Child()
-	clgr.Set("error", err.Error())
+	clgr := lgr.child()
+	clgr.set(Field{Key: lgr.format.Keys.Error, Value: NewErrorInfo(err)})
+	return clgr
+}
+
+// WithNamedError adds err to the returned lager under key, if non nil
+func (lgr *contextLager) WithNamedError(key string, err error) ContextLager {
+	if err == nil {
+		return lgr
+	}
+
+	clgr := lgr.child()
+	clgr.set(Field{Key: key, Value: NewErrorInfo(err)})
+	return clgr
+}
+
+// WithErrors adds the non nil errs to the list of errors of the returned lager,
+// after any the lager already has
+func (lgr *contextLager) WithErrors(errs ...error) ContextLager {
+	key, err := lgr.keyPolicy.key(lgr.reserved, lgr.format.Keys.Errors)
+	if err != nil || lgr.reserved(key) {
+		lgr.handleError(err)
+		return lgr
+	}
+
+	list, _ := lgr.fields.Get(key)
+	errList, _ := list.(ErrorList)
+
+	errList = errList.with(errs...)
+	if len(errList) == 0 {
+		return lgr
+	}
+
+	clgr := lgr.child()
+	clgr.fields = lgr.fields.with(Field{Key: key, Value: errList})
 	return clgr
 }
 
 // Set sets a key to value in the lager map
 func (lgr *contextLager) Set(key, value string) ContextLager {
-	lgr.values[key] = value
+	lgr.set(Field{Key: key, Value: value})
+	return lgr
+}
+
+// SetLazy sets a key to a value that is only evaluated when a log is written
+func (lgr *contextLager) SetLazy(key string, value Valuer) ContextLager {
+	lgr.set(Field{Key: key, Value: value})
 	return lgr
 }
 
 func (lgr *contextLager) Unset(key string) ContextLager {
-	delete(lgr.values, key)
+	if key, err := lgr.keyPolicy.key(lgr.reserved, key); err == nil {
+		lgr.fields = lgr.fields.without(key)
+	}
 	return lgr
 }
 
-//Logf writes a log to the standard output
+// set sets fields under the keys given by the key policy
+func (lgr *contextLager) set(fields ...Field) {
+	keyed := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		key, err := lgr.keyPolicy.key(lgr.reserved, field.Key)
+		if err != nil {
+			lgr.handleError(err)
+			continue
+		}
+
+		// the standard values take the place of any left reserved
+		if lgr.reserved(key) {
+			continue
+		}
+
+		keyed = append(keyed, Field{Key: key, Value: field.Value})
+	}
+
+	lgr.fields = lgr.fields.with(keyed...)
+}
+
+// reserved returns whether key is the key of a standard value the lager logs.
+// The file, func and stacktrace keys are only reserved if those values are
+// logged.
+func (lgr *contextLager) reserved(key string) bool {
+	keys := lgr.format.Keys
+	return key == keys.Time || key == keys.Level || key == keys.Message ||
+		key == keys.File && lgr.fileType != NoFile ||
+		key == keys.Func && lgr.funcName ||
+		key == keys.Stacktrace && lgr.stacktraces
+}
+
+func (lgr *contextLager) handleError(err error) {
+	if err != nil && lgr.errorHandler != nil {
+		lgr.errorHandler(err)
+	}
+}
+
+// Logf writes a log to the standard output
 func (lgr *contextLager) Logf(lvl Level, message string, v ...interface{}) {
-	allValues := make(map[string]interface{})
-	for k, v := range lgr.values {
-		allValues[k] = v
+	// Logf is called by lager.logf, called by the Lager level method the user called
+	lgr.log(4, lvl, message, v...)
+}
+
+// logf logs if lvl is enabled, for callers that do not go through the Lager
+// level methods. calldepth is the depth of the user's call from logf's caller.
+func (lgr *contextLager) logf(calldepth int, lvl Level, message string, v ...interface{}) {
+	if !lgr.Enabled(lvl) {
+		return
+	}
+
+	lgr.log(calldepth+2, lvl, message, v...)
+}
+
+// TraceFn logs the result of fn with level Trace, calling fn only if enabled
+func (lgr *contextLager) TraceFn(fn LogFunc) {
+	lgr.logFn(1, Trace, fn)
+}
+
+// DebugFn logs the result of fn with level Debug, calling fn only if enabled
+func (lgr *contextLager) DebugFn(fn LogFunc) {
+	lgr.logFn(1, Debug, fn)
+}
+
+// InfoFn logs the result of fn with level Info, calling fn only if enabled
+func (lgr *contextLager) InfoFn(fn LogFunc) {
+	lgr.logFn(1, Info, fn)
+}
+
+// WarnFn logs the result of fn with level Warn, calling fn only if enabled
+func (lgr *contextLager) WarnFn(fn LogFunc) {
+	lgr.logFn(1, Warn, fn)
+}
+
+// ErrorFn logs the result of fn with level Error, calling fn only if enabled
+func (lgr *contextLager) ErrorFn(fn LogFunc) {
+	lgr.logFn(1, Error, fn)
+}
+
+// logFn logs the result of fn if lvl is enabled. calldepth is the depth of the
+// user's call from logFn's caller.
+func (lgr *contextLager) logFn(calldepth int, lvl Level, fn LogFunc) {
+	if !lgr.Enabled(lvl) {
+		return
+	}
+
+	msg, fields := fn()
+
+	clgr := lgr
+	if len(fields) > 0 {
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		list := make([]Field, len(keys))
+		for i, key := range keys {
+			list[i] = Field{Key: key, Value: fields[key]}
+		}
+
+		clgr = lgr.child()
+		clgr.set(list...)
 	}
 
-	if lvl == Error && lgr.stacktraces {
-		allValues["stacktrace"] = string(debug.Stack())
+	clgr.log(calldepth+2, lvl, "%s", msg)
+}
+
+// log writes a log. calldepth is the depth of the user's call from log,
+// counting as runtime.Caller does.
+func (lgr *contextLager) log(calldepth int, lvl Level, message string, v ...interface{}) {
+	e := lgr.newEntry(lvl, message, v...)
+
+	if lgr.fileType != NoFile || lgr.funcName {
+		if frame, ok := callerFrame(calldepth + lgr.callerSkip); ok {
+			e.File = lgr.fileType.Format(frame)
+			if lgr.funcName {
+				e.Func = frame.Function
+			}
+		}
+	}
+
+	if lgr.stacktraces && lgr.stackLevels&lvl != 0 {
+		e.Stack = captureStack(calldepth+lgr.callerSkip, lgr.stackDepth)
 	}
 
-	file := lgr.fileType.Caller(5)
-	if file != "" {
-		allValues["file"] = file
+	lgr.drink(e)
+}
+
+// newEntry creates an entry with the lager's fields
+func (lgr *contextLager) newEntry(lvl Level, message string, v ...interface{}) *Entry {
+	return &Entry{
+		Time:    lgr.clock.Now(),
+		Level:   lvl,
+		Message: fmt.Sprintf(message, resolveArgs(v)...),
+		Fields:  lgr.fields.Ordered(lgr.fieldOrder).Resolved(),
+		Format:  lgr.format,
 	}
+}
 
-	//add all standard values
-	allValues["time"] = time.Now().UTC().Format(time.RFC3339)
-	allValues["msg"] = fmt.Sprintf(message, v...)
-	allValues["level"] = lvl.String()
+// drink hands e to the drinker
+func (lgr *contextLager) drink(e *Entry) {
+	if drinker, ok := lgr.drinker.(EntryDrinker); ok {
+		lgr.handleError(drinker.DrinkEntry(e))
+		return
+	}
 
-	//not sure what to do if the logger fails here
-	lgr.drinker.Drink(allValues)
+	lgr.handleError(lgr.drinker.Drink(e.Map()))
 }
 
 // Child creates a child ContextLager from this, the parent.
 // The child inherits all the parent values.
 func (lgr *contextLager) Child() ContextLager {
-	return NewContextLager(&ContextConfig{
-		Levels:      lgr.Levels(),
-		Drinker:     lgr.drinker,
-		Values:      lgr.values,
-		Stacktraces: lgr.stacktraces,
-		FileType:    lgr.fileType,
-	})
+	return lgr.child()
+}
+
+// AddCallerSkip creates a child that skips skip more frames when finding the
+// file and function that logged, for use by helper functions wrapping it.
+func (lgr *contextLager) AddCallerSkip(skip int) ContextLager {
+	clgr := lgr.child()
+	clgr.callerSkip += skip
+	return clgr
+}
+
+// child creates a child that shares the parent's fields, and the encodings
+// cached for them, until either sets a value.
+func (lgr *contextLager) child() *contextLager {
+	clgr := &contextLager{
+		drinker:     lgr.drinker,
+		fields:      lgr.fields,
+		stacktraces: lgr.stacktraces,
+		stackLevels: lgr.stackLevels,
+		stackDepth:  lgr.stackDepth,
+		fileType:    lgr.fileType,
+		funcName:    lgr.funcName,
+		callerSkip:  lgr.callerSkip,
+		fieldOrder:  lgr.fieldOrder,
+		keyPolicy:   lgr.keyPolicy,
+		format:      lgr.format,
+		clock:       lgr.clock,
+
+		errorHandler: lgr.errorHandler,
+	}
+
+	clgr.Lager = newLager(clgr, lgr.Levels())
+	return clgr
 }
@@ -134,6 +134,38 @@ func BenchmarkJSONContextLagerAllLevel(b *testing.B) {
 	}
 }
 
+func BenchmarkJSONContextLagerChildAllLevel(b *testing.B) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	lgr := NewContextLager(&ContextConfig{
+		Levels:      new(Levels).Set(Error | Warn | Info | Debug | Trace),
+		Drinker:     NewJSONDrinker(f),
+		Stacktraces: false,
+	})
+
+	lgr.Set("app", "benchmark")
+	lgr.Set("type", "yes")
+
+	for i := 0; i < 10; i++ {
+		lgr = lgr.With(map[string]string{fmt.Sprintf("depth%d", i): "deep"})
+	}
+
+	msg := "test"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lgr.Tracef("This is a %s", msg)
+		lgr.Debugf("This is a %s", msg)
+		lgr.Infof("This is a %s", msg)
+		lgr.Warnf("This is a %s", msg)
+		lgr.Errorf("This is a %s", msg)
+	}
+}
+
 func BenchmarkContextPivotalLagerAllLevels(b *testing.B) {
 	f, err := os.Open(os.DevNull)
 	if err != nil {
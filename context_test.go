@@ -400,13 +400,19 @@ func TestContextWithErr(t *testing.T) {
 	err := errors.New("failure")
 	logger.WithError(err).Tracef("hello world")
 
-	var actual map[string]string
+	var actual struct {
+		Error ErrorInfo `json:"error"`
+	}
 	if err := dec.Decode(&actual); err != nil {
 		t.Fatal(err)
 	}
 
-	if actual["error"] != err.Error() {
-		t.Fatalf("expected error to be failure, got %s", actual["error"])
+	if actual.Error.Message != err.Error() {
+		t.Fatalf("expected error to be failure, got %s", actual.Error.Message)
+	}
+
+	if actual.Error.Type != "*errors.errorString" {
+		t.Fatalf("expected error type *errors.errorString, got %s", actual.Error.Type)
 	}
 }
 
@@ -435,3 +441,516 @@ func TestContextWithErrNil(t *testing.T) {
 func TestContextLager(t *testing.T) {
 	NewContextLager(nil)
 }
+
+func TestContextChildIsolated(t *testing.T) {
+	buf := new(bytes.Buffer)
+	dec := json.NewDecoder(buf)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Trace),
+		Drinker: NewJSONDrinker(buf),
+	})
+
+	logger.Set("a", "one")
+	child := logger.Child()
+	logger.Set("b", "two")
+	child.Set("a", "uno")
+
+	logger.Tracef("parent")
+	child.Tracef("child")
+
+	var parent, actual map[string]string
+	if err := dec.Decode(&parent); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if parent["a"] != "one" || parent["b"] != "two" {
+		t.Fatalf("expected parent a == one and b == two, got %v", parent)
+	}
+
+	if actual["a"] != "uno" {
+		t.Fatalf("expected child a == uno, got %s", actual["a"])
+	}
+
+	if _, ok := actual["b"]; ok {
+		t.Fatalf("unexpected key b in child")
+	}
+}
+
+func TestContextChildSharesFields(t *testing.T) {
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Trace),
+		Drinker: NewJSONDrinker(ioutil.Discard),
+	})
+
+	logger.Set("a", "one")
+	child := logger.Child().Child()
+
+	if logger.(*contextLager).fields != child.(*contextLager).fields {
+		t.Fatal("expected child to share fields with parent")
+	}
+}
+
+func TestContextLogDrinkerEntry(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Trace),
+		Drinker: NewLogDrinker(buf),
+	})
+
+	logger.Set("msg", "clobbered")
+	logger.With(map[string]string{"a": "one"}).Tracef("hello")
+
+	actual := buf.String()
+	if !strings.Contains(actual, "msg=hello") {
+		t.Fatalf("expected msg=hello in '%s'", actual)
+	}
+
+	if !strings.HasSuffix(actual, "a=one \n") {
+		t.Fatalf("expected a=one at the end of '%s'", actual)
+	}
+}
+
+func TestContextFieldOrder(t *testing.T) {
+	tests := []struct {
+		order    FieldOrder
+		expected string
+	}{
+		{InsertionOrder, `"msg":"hello","c":"three","a":"one","b":"two"}`},
+		{SortedOrder, `"msg":"hello","a":"one","b":"two","c":"three"}`},
+	}
+
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+
+		logger := NewContextLager(&ContextConfig{
+			Levels:     new(Levels).Set(Trace),
+			Drinker:    NewJSONDrinker(buf),
+			FieldOrder: test.order,
+		})
+
+		logger.Set("c", "three").Set("a", "one").Set("b", "two")
+		logger.Child().Tracef("hello")
+
+		if !strings.HasSuffix(buf.String(), test.expected+"\n") {
+			t.Fatalf("expected '%s' to end with '%s'", buf.String(), test.expected)
+		}
+	}
+}
+
+func TestContextKeyPolicy(t *testing.T) {
+	tests := []struct {
+		policy   KeyPolicy
+		expected map[string]string
+	}{
+		{OverwriteReserved, map[string]string{"msg": "hello", "a": "one"}},
+		{RenameReserved, map[string]string{"msg": "hello", "fields.msg": "clobbered", "a": "one"}},
+		{PrefixFields, map[string]string{"msg": "hello", "fields.msg": "clobbered", "fields.a": "one"}},
+		{RejectReserved, map[string]string{"msg": "hello", "a": "one"}},
+	}
+
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		var errs []error
+
+		logger := NewContextLager(&ContextConfig{
+			Levels:       new(Levels).Set(Trace),
+			Drinker:      NewJSONDrinker(buf),
+			KeyPolicy:    test.policy,
+			ErrorHandler: func(err error) { errs = append(errs, err) },
+		})
+
+		logger.Set("msg", "clobbered").Set("a", "one").Tracef("hello")
+
+		var actual map[string]string
+		if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+			t.Fatal(err)
+		}
+
+		delete(actual, "time")
+		delete(actual, "level")
+		if len(actual) != len(test.expected) {
+			t.Fatalf("policy %d: expected %v, got %v", test.policy, test.expected, actual)
+		}
+
+		for k, v := range test.expected {
+			if actual[k] != v {
+				t.Fatalf("policy %d: expected %s == %s, got %s", test.policy, k, v, actual[k])
+			}
+		}
+
+		if test.policy != RejectReserved {
+			continue
+		}
+
+		if len(errs) != 1 {
+			t.Fatalf("expected 1 error, got %d", len(errs))
+		}
+
+		if err, ok := errs[0].(*ReservedKeyError); !ok || err.Key != "msg" {
+			t.Fatalf("expected ReservedKeyError for msg, got %v", errs[0])
+		}
+	}
+}
+
+func TestContextReservedOnlyIfLogged(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:   new(Levels).Set(Info),
+		Drinker:  NewJSONDrinker(buf),
+		FileType: NoFile,
+	})
+
+	logger.Set("file", "upload.txt").Set("func", "main").Set("stacktrace", "none").Infof("hello")
+
+	var actual map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual["file"] != "upload.txt" || actual["func"] != "main" || actual["stacktrace"] != "none" {
+		t.Fatalf("expected the file, func and stacktrace fields, got %v", actual)
+	}
+}
+
+func TestContextPrefixFieldsErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:    new(Levels).Set(Info),
+		Drinker:   NewJSONDrinker(buf),
+		KeyPolicy: PrefixFields,
+	})
+
+	err := errors.New("great scott")
+	logger.WithError(err).WithErrors(err, err).WithNamedError("cause", err).Infof("hello")
+
+	var actual map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"fields.error", "fields.errors", "fields.cause"} {
+		if _, ok := actual[key]; !ok {
+			t.Fatalf("expected %s, got %v", key, actual)
+		}
+	}
+
+	errs, _ := actual["fields.errors"].([]interface{})
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", actual["fields.errors"])
+	}
+}
+
+func TestContextPartialFormat(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:   new(Levels).Set(Info),
+		Drinker:  NewJSONDrinker(buf),
+		FileType: ShortFile,
+		Format:   &Format{Keys: Keys{Time: "@timestamp", Level: "severity"}},
+		Clock:    fixedClock(time.Date(2015, 10, 21, 16, 29, 0, 0, time.UTC)),
+	})
+
+	logger.Infof("hello")
+
+	var actual map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual["@timestamp"] != "2015-10-21T16:29:00Z" || actual["severity"] != "Info" || actual["msg"] != "hello" {
+		t.Fatalf("expected the empty keys and time layout to be the defaults, got %v", actual)
+	}
+	if _, ok := actual["file"]; !ok {
+		t.Fatalf("expected the file under the default key, got %v", actual)
+	}
+	if _, ok := actual[""]; ok {
+		t.Fatalf("expected no empty key, got %v", actual)
+	}
+}
+
+func TestContextFormat(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	format := DefaultFormat()
+	format.Keys.Time = "@timestamp"
+	format.Keys.Message = "message"
+	format.Keys.Level = "severity"
+	format.Keys.Error = "err"
+	format.TimeLayout = UnixMillis
+	format.LowerCaseLevels = true
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Trace),
+		Drinker: NewJSONDrinker(buf),
+		Format:  format,
+	})
+
+	logger.Set("message", "clobbered").Set("msg", "kept")
+	logger.WithError(errors.New("failure")).Tracef("hello")
+
+	var actual map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"message":  "hello",
+		"severity": "trace",
+		"msg":      "kept",
+	}
+
+	for k, v := range expected {
+		if actual[k] != v {
+			t.Fatalf("expected %s == %v, got %v", k, v, actual[k])
+		}
+	}
+
+	if errInfo, ok := actual["err"].(map[string]interface{}); !ok || errInfo["message"] != "failure" {
+		t.Fatalf("expected err message failure, got %v", actual["err"])
+	}
+
+	if _, ok := actual["@timestamp"].(float64); !ok {
+		t.Fatalf("expected numeric @timestamp, got %v", actual["@timestamp"])
+	}
+}
+
+func TestContextFuncName(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:   new(Levels).Set(Trace),
+		Drinker:  NewJSONDrinker(buf),
+		FileType: ShortFile,
+		FuncName: true,
+	})
+
+	logger.Child().Tracef("hello")
+
+	var logMap map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &logMap); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "github.com/doubledutch/lager.TestContextFuncName"; logMap["func"] != expected {
+		t.Fatalf("expected func %s, got %s", expected, logMap["func"])
+	}
+
+	if !strings.HasPrefix(logMap["file"], "context_test.go:") {
+		t.Fatalf("expected file context_test.go, got %s", logMap["file"])
+	}
+}
+
+// logHelper wraps a ContextLager the way applications do
+func logHelper(lgr ContextLager, msg string) {
+	lgr.Infof("helper: %s", msg)
+}
+
+func TestContextCallerSkip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	dec := json.NewDecoder(buf)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:     new(Levels).Set(Info),
+		Drinker:    NewJSONDrinker(buf),
+		FileType:   ShortFile,
+		FuncName:   true,
+		CallerSkip: 1,
+	})
+
+	logHelper(logger, "config")
+	logHelper(logger.Child().AddCallerSkip(-1).AddCallerSkip(1), "child")
+	logHelper(logger.AddCallerSkip(-1), "unskipped")
+
+	expected := []string{
+		"github.com/doubledutch/lager.TestContextCallerSkip",
+		"github.com/doubledutch/lager.TestContextCallerSkip",
+		"github.com/doubledutch/lager.logHelper",
+	}
+
+	for _, function := range expected {
+		var logMap map[string]string
+		if err := dec.Decode(&logMap); err != nil {
+			t.Fatal(err)
+		}
+
+		if logMap["func"] != function {
+			t.Fatalf("%s: expected func %s, got %s", logMap["msg"], function, logMap["func"])
+		}
+	}
+}
+
+func TestPackageFuncsCaller(t *testing.T) {
+	buf := new(bytes.Buffer)
+	lgr := defaultLager.(*contextLager)
+
+	drinker, fileType, levels := lgr.drinker, lgr.fileType, new(Levels).Replace(lgr.Levels())
+	defer func() {
+		SetDrinker(drinker)
+		SetFileType(fileType)
+		SetLevels(levels)
+	}()
+
+	SetDrinker(NewJSONDrinker(buf))
+	SetFileType(ShortFile)
+	SetLevels(new(Levels).All())
+
+	Tracef("package")
+	Child().Tracef("child")
+
+	dec := json.NewDecoder(buf)
+	for i := 0; i < 2; i++ {
+		var logMap map[string]string
+		if err := dec.Decode(&logMap); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(logMap["file"], "context_test.go:") {
+			t.Fatalf("%s: expected file context_test.go, got %s", logMap["msg"], logMap["file"])
+		}
+	}
+}
+
+func TestSetStacktraceLevelsZero(t *testing.T) {
+	lgr := defaultLager.(*contextLager)
+
+	levels := lgr.stackLevels
+	defer SetStacktraceLevels(levels)
+
+	SetStacktraceLevels(Warn)
+	SetStacktraceLevels(0)
+	if lgr.stackLevels != Error {
+		t.Fatalf("expected zero to mean Error, got %v", lgr.stackLevels)
+	}
+}
+
+func TestContextStructuredStacktrace(t *testing.T) {
+	buf := new(bytes.Buffer)
+	dec := json.NewDecoder(buf)
+
+	format := DefaultFormat()
+	format.StructuredStacktraces = true
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:           new(Levels).All(),
+		Drinker:          NewJSONDrinker(buf),
+		Stacktraces:      true,
+		StacktraceLevels: Warn | Error,
+		StacktraceDepth:  2,
+		Format:           format,
+	})
+
+	logger.Infof("no stack")
+	logger.Warnf("stack")
+
+	var info map[string]interface{}
+	if err := dec.Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := info["stacktrace"]; ok {
+		t.Fatal("unexpected stacktrace for Info")
+	}
+
+	var warn struct {
+		Stacktrace Stack `json:"stacktrace"`
+	}
+	if err := dec.Decode(&warn); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(warn.Stacktrace) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(warn.Stacktrace))
+	}
+
+	frame := warn.Stacktrace[0]
+	if frame.Func != "github.com/doubledutch/lager.TestContextStructuredStacktrace" {
+		t.Fatalf("expected first frame to be the test, got %s", frame.Func)
+	}
+
+	if !strings.HasSuffix(frame.File, "context_test.go") || frame.Line == 0 {
+		t.Fatalf("expected file context_test.go with a line, got %s:%d", frame.File, frame.Line)
+	}
+}
+
+func TestContextStacktraceTrimmed(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:      new(Levels).Set(Error),
+		Drinker:     NewJSONDrinker(buf),
+		Stacktraces: true,
+	})
+
+	logger.Errorf("stack")
+
+	var logMap map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &logMap); err != nil {
+		t.Fatal(err)
+	}
+
+	stack := logMap["stacktrace"]
+	if !strings.HasPrefix(stack, "github.com/doubledutch/lager.TestContextStacktraceTrimmed()\n") {
+		t.Fatalf("expected stacktrace to start at the test, got %s", stack)
+	}
+
+	if strings.Contains(stack, "goroutine") {
+		t.Fatalf("unexpected goroutine header in %s", stack)
+	}
+}
+
+func TestContextLogFn(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:   new(Levels).Set(Info),
+		Drinker:  NewJSONDrinker(buf),
+		FileType: ShortFile,
+	})
+
+	calls := 0
+	fn := func() (string, map[string]interface{}) {
+		calls++
+		return "dump", map[string]interface{}{"size": 42}
+	}
+
+	logger.DebugFn(fn)
+	if calls != 0 || buf.Len() != 0 {
+		t.Fatalf("expected no call or log for a disabled level, got %d calls", calls)
+	}
+
+	logger.InfoFn(fn)
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+
+	var actual struct {
+		Msg  string `json:"msg"`
+		File string `json:"file"`
+		Size int    `json:"size"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Msg != "dump" || actual.Size != 42 {
+		t.Fatalf("expected dump with size 42, got %+v", actual)
+	}
+
+	if !strings.HasPrefix(actual.File, "context_test.go:") {
+		t.Fatalf("expected file context_test.go, got %s", actual.File)
+	}
+
+	buf.Reset()
+	logger.InfoFn(func() (string, map[string]interface{}) { return "100%", nil })
+	if !strings.Contains(buf.String(), `"msg":"100%"`) {
+		t.Fatalf("expected message to be logged as is, got '%s'", buf.String())
+	}
+}
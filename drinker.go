@@ -15,7 +15,10 @@ package lager
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"sort"
+	"strings"
 )
 
 // ErrNoDrinker is used when a drinker cannot be returned, primarly DrinkerFromString
@@ -26,6 +29,19 @@ type Drinker interface {
 	Drink(v map[string]interface{}) error
 }
 
+// EntryDrinker is a Drinker that can drink an Entry directly. ContextLager
+// prefers DrinkEntry, which lets a drinker reuse the encoding of context fields
+// cached on the Entry's Fields instead of encoding them for every log.
+type EntryDrinker interface {
+	Drinker
+	DrinkEntry(e *Entry) error
+}
+
+// Flusher is implemented by Drinkers that buffer logs
+type Flusher interface {
+	Flush() error
+}
+
 // NewDrinkerFunc creates a new drinker
 type NewDrinkerFunc func(output io.Writer) Drinker
 
@@ -37,7 +53,63 @@ func DrinkerFromString(str string) (NewDrinkerFunc, error) {
 		return NewJSONDrinker, nil
 	case "LOG":
 		return NewLogDrinker, nil
+	case "LOGFMT":
+		return NewLogfmtDrinker, nil
+	case "CONSOLE":
+		return NewConsoleDrinker, nil
 	default:
 		return nil, ErrNoDrinker
 	}
 }
+
+// orderedKeys returns the keys of v in the order they are logged: the default
+// standard keys first, followed by all others sorted.
+func orderedKeys(v map[string]interface{}) []string {
+	keys := make([]string, 0, len(v))
+	for _, key := range defaultFormat.standardKeys() {
+		if _, ok := v[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	others := make([]string, 0, len(v)-len(keys))
+	for key := range v {
+		if !defaultFormat.reserved(key) {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+
+	return append(keys, others...)
+}
+
+// escapeControl returns s with line breaks and other control characters
+// escaped, so text logs cannot be forged by input containing them
+func escapeControl(s string) string {
+	i := strings.IndexFunc(s, isControl)
+	if i < 0 {
+		return s
+	}
+
+	b := new(strings.Builder)
+	b.WriteString(s[:i])
+	for _, r := range s[i:] {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case isControl(r):
+			fmt.Fprintf(b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isControl returns whether r is a control character other than tab,
+// or a Unicode line or paragraph separator
+func isControl(r rune) bool {
+	return (r < ' ' && r != '\t') || (r >= 0x7f && r <= 0x9f) || r == '\u2028' || r == '\u2029'
+}
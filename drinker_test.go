@@ -0,0 +1,154 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTime is the time of the logs of newTestLager
+var testTime = time.Date(2015, 10, 21, 16, 29, 0, 123e6, time.UTC)
+
+// newTestLager creates a lager that logs all levels to drinker at testTime,
+// closing drinker when the test completes if it is an io.Closer
+func newTestLager(t *testing.T, drinker Drinker) ContextLager {
+	if closer, ok := drinker.(io.Closer); ok {
+		t.Cleanup(func() { closer.Close() })
+	}
+
+	return NewContextLager(&ContextConfig{
+		Levels:  new(Levels).All(),
+		Drinker: drinker,
+		Clock:   fixedClock(testTime),
+	})
+}
+
+func testDrinkerMap() map[string]interface{} {
+	return map[string]interface{}{
+		"b":     "two",
+		"msg":   "hello",
+		"a":     "one",
+		"level": "Error",
+		"file":  "lager.go:1",
+		"time":  "2015-10-21T16:29:00Z",
+	}
+}
+
+func TestLogDrinkerOrder(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	expected := "time=\"2015-10-21T16:29:00Z\" level=Error msg=hello file=\"lager.go:1\" a=one b=two \n"
+	for i := 0; i < 10; i++ {
+		buf.Reset()
+		if err := NewLogDrinker(buf).Drink(testDrinkerMap()); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != expected {
+			t.Fatalf("expected '%s', got '%s'", expected, buf.String())
+		}
+	}
+}
+
+func TestJSONDrinkerOrder(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	expected := `{"time":"2015-10-21T16:29:00Z","level":"Error","msg":"hello","file":"lager.go:1","a":"one","b":"two"}` + "\n"
+	for i := 0; i < 10; i++ {
+		buf.Reset()
+		if err := NewJSONDrinker(buf).Drink(testDrinkerMap()); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != expected {
+			t.Fatalf("expected '%s', got '%s'", expected, buf.String())
+		}
+	}
+}
+
+func TestTextDrinkersEscapeControlChars(t *testing.T) {
+	drinkers := map[string]NewDrinkerFunc{
+		"LOG":     NewLogDrinker,
+		"LOGFMT":  NewLogfmtDrinker,
+		"CONSOLE": NewConsoleDrinker,
+	}
+
+	forged := "level=Error msg=forged"
+	attacks := []string{
+		"ok\n" + forged,
+		"ok\r\n" + forged,
+		"ok\r" + forged,
+		"ok\u2028" + forged,
+		"ok\x1b[2K\r" + forged,
+	}
+
+	for name, newDrinker := range drinkers {
+		for _, attack := range attacks {
+			buf := new(bytes.Buffer)
+
+			logger := NewContextLager(&ContextConfig{
+				Levels:  new(Levels).Set(Info),
+				Drinker: newDrinker(buf),
+			})
+
+			logger.Set(attack, attack).Set("value", attack).Infof("%s", attack)
+
+			actual := buf.String()
+			if strings.Count(actual, "\n") != 1 || !strings.HasSuffix(actual, "\n") {
+				t.Fatalf("%s: expected a single line, got %q", name, actual)
+			}
+
+			if strings.ContainsAny(actual, "\r\x1b ") {
+				t.Fatalf("%s: unexpected control character in %q", name, actual)
+			}
+		}
+	}
+}
+
+func TestRawLogDrinker(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	NewRawLogDrinker(buf).Drink(map[string]interface{}{"multi\nline": 1})
+	if !strings.Contains(buf.String(), "multi\nline=1") {
+		t.Fatalf("expected key as is, got %q", buf.String())
+	}
+
+	buf.Reset()
+	NewLogDrinker(buf).Drink(map[string]interface{}{"multi\nline": 1})
+	if !strings.Contains(buf.String(), `multi\nline=1`) {
+		t.Fatalf("expected escaped key, got %q", buf.String())
+	}
+}
+
+func TestEscapeControl(t *testing.T) {
+	tests := map[string]string{
+		"plain":     "plain",
+		"tab\tkept": "tab\tkept",
+		"a\nb\rc":   `a\nb\rc`,
+		"bell\x07":  `bell\u0007`,
+		"nel\u0085": `nel\u0085`,
+		"sep\u2028": `sep\u2028`,
+		"unicode ☃": "unicode ☃",
+	}
+
+	for input, expected := range tests {
+		if actual := escapeControl(input); actual != expected {
+			t.Fatalf("%q: expected %q, got %q", input, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ElasticsearchConfig is the configuration for ElasticsearchDrinker
+type ElasticsearchConfig struct {
+	// URL is the address of the cluster, such as http://localhost:9200
+	URL string
+	// Client sends batches, a client with a 30 second timeout if nil
+	Client *http.Client
+	// Header is added to each request
+	Header http.Header
+	// Username and Password are sent with basic authentication if Username is
+	// set
+	Username string
+	Password string
+
+	// IndexPrefix is the fixed start of the index each entry is written to,
+	// such as logs-
+	IndexPrefix string
+	// IndexDateLayout is the time layout of the rest of the index, formatted
+	// with the entry's time in UTC, such as 2006.01.02. Entries are all written
+	// to IndexPrefix if empty.
+	IndexDateLayout string
+	// Gzip compresses requests with gzip
+	Gzip bool
+
+	Batch BatchConfig
+}
+
+// DefaultElasticsearchConfig creates a default ElasticsearchConfig
+func DefaultElasticsearchConfig() *ElasticsearchConfig {
+	return &ElasticsearchConfig{
+		URL:             "http://localhost:9200",
+		IndexPrefix:     "logs-",
+		IndexDateLayout: "2006.01.02",
+		Gzip:            true,
+		Batch:           *DefaultBatchConfig(),
+	}
+}
+
+// ElasticsearchDrinker is a Drinker that writes logs to Elasticsearch or
+// OpenSearch in batches with the bulk API, each to the index for its day.
+// Requests that fail with a network error, a 429 or 5xx status are sent
+// again with backoff, as are only the logs that the bulk response reports
+// failed with a 429 or 5xx status. Other failed logs are dropped.
+type ElasticsearchDrinker struct {
+	url          string
+	client       *http.Client
+	header       http.Header
+	indexPrefix  string
+	indexLayout  string
+	gzip         bool
+	errorHandler func(error)
+
+	batcher *batcher
+}
+
+// NewElasticsearchDrinker creates a new Elasticsearch Drinker and starts
+// sending batches
+func NewElasticsearchDrinker(config *ElasticsearchConfig) (*ElasticsearchDrinker, error) {
+	if config == nil {
+		config = DefaultElasticsearchConfig()
+	}
+
+	if err := checkHTTPURL(config.URL); err != nil {
+		return nil, err
+	}
+
+	if config.IndexPrefix == "" && config.IndexDateLayout == "" {
+		return nil, errors.New("elasticsearch: an index is required")
+	}
+
+	drkr := &ElasticsearchDrinker{
+		url:          strings.TrimSuffix(config.URL, "/") + "/_bulk",
+		client:       config.Client,
+		header:       make(http.Header),
+		indexPrefix:  config.IndexPrefix,
+		indexLayout:  config.IndexDateLayout,
+		gzip:         config.Gzip,
+		errorHandler: config.Batch.ErrorHandler,
+	}
+
+	if drkr.client == nil {
+		drkr.client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	for key, values := range config.Header {
+		drkr.header[key] = values
+	}
+
+	if config.Username != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(config.Username + ":" + config.Password))
+		drkr.header.Set("Authorization", "Basic "+auth)
+	}
+
+	btchr, err := newBatcher(config.Batch, drkr.send)
+	if err != nil {
+		return nil, err
+	}
+
+	drkr.batcher = btchr
+	return drkr, nil
+}
+
+// Drink drinks logs, using the default keys for the standard values
+func (drkr *ElasticsearchDrinker) Drink(v map[string]interface{}) error {
+	return drkr.DrinkEntry(entryFromMap(v))
+}
+
+// DrinkEntry queues the log to be written, reusing the cached JSON encoding
+// of the entry's fields
+func (drkr *ElasticsearchDrinker) DrinkEntry(e *Entry) error {
+	b := new(bytes.Buffer)
+	if err := appendJSONEntry(b, e, drkr); err != nil {
+		return err
+	}
+
+	index := drkr.indexPrefix
+	if drkr.indexLayout != "" {
+		index += e.Time.UTC().Format(drkr.indexLayout)
+	}
+
+	return drkr.batcher.add(batchItem{key: index, data: b.Bytes()})
+}
+
+// Flush writes the queued logs
+func (drkr *ElasticsearchDrinker) Flush() error {
+	return drkr.batcher.flush()
+}
+
+// Close writes the queued logs and stops writing. Logs drunk after it are
+// dropped.
+func (drkr *ElasticsearchDrinker) Close() error {
+	return drkr.batcher.close()
+}
+
+// bulkResponse is the part of a bulk API response used to find failed items
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// send writes items, returning those that should be sent again
+func (drkr *ElasticsearchDrinker) send(items []batchItem) ([]batchItem, error) {
+	b := new(bytes.Buffer)
+	for _, item := range items {
+		action, err := json.Marshal(map[string]map[string]string{
+			"create": {"_index": item.key},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("dropped %d logs: %v", len(items), err)
+		}
+
+		b.Write(action)
+		b.WriteByte('\n')
+		b.Write(item.data)
+		b.WriteByte('\n')
+	}
+
+	resp, err := httpPost(drkr.client, drkr.url, drkr.header, "application/x-ndjson", b.Bytes(), drkr.gzip)
+	if err != nil {
+		return items, err
+	}
+	defer discardBody(resp)
+
+	if err := httpStatusError(resp); err != nil {
+		if httpRetryable(resp.StatusCode) {
+			return items, err
+		}
+		return nil, fmt.Errorf("dropped %d logs: %v", len(items), err)
+	}
+
+	var bulk bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&bulk); err != nil {
+		return nil, fmt.Errorf("elasticsearch: invalid bulk response: %v", err)
+	}
+
+	if !bulk.Errors {
+		return nil, nil
+	}
+
+	if len(bulk.Items) != len(items) {
+		return nil, fmt.Errorf("elasticsearch: bulk response has %d items for %d logs", len(bulk.Items), len(items))
+	}
+
+	var retry []batchItem
+	var retryErr, dropErr error
+	dropped := 0
+	for i, result := range bulk.Items {
+		for _, status := range result {
+			if status.Status >= 200 && status.Status < 300 {
+				continue
+			}
+
+			err := fmt.Errorf("elasticsearch: index %s: status %d", items[i].key, status.Status)
+			if status.Error != nil {
+				err = fmt.Errorf("elasticsearch: index %s: %s: %s", items[i].key, status.Error.Type, status.Error.Reason)
+			}
+
+			if httpRetryable(status.Status) {
+				retry = append(retry, items[i])
+				retryErr = err
+			} else {
+				dropped++
+				dropErr = err
+			}
+		}
+	}
+
+	if dropped > 0 {
+		dropErr = fmt.Errorf("dropped %d logs: %v", dropped, dropErr)
+		if len(retry) == 0 {
+			return nil, dropErr
+		}
+
+		// the error of the items sent again is returned, so report these now
+		if drkr.errorHandler != nil {
+			drkr.errorHandler(dropErr)
+		}
+	}
+
+	return retry, retryErr
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBulkRecorder creates an httpRecorder for the bulk API, which records
+// each document with its index under "_index" and fails the documents whose
+// message is in fail with its status once
+func newBulkRecorder(fail map[string]int) *httpRecorder {
+	return &httpRecorder{decode: func(w http.ResponseWriter, body []byte) ([]map[string]interface{}, error) {
+		var docs []map[string]interface{}
+		var items []string
+		failed := false
+
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			var action map[string]map[string]string
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				return nil, err
+			}
+
+			doc := make(map[string]interface{})
+			if !scanner.Scan() {
+				return nil, errors.New("missing document")
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+				return nil, err
+			}
+
+			msg, _ := doc["msg"].(string)
+			if status, ok := fail[msg]; ok {
+				delete(fail, msg)
+				failed = true
+				items = append(items, fmt.Sprintf(`{"create":{"status":%d,"error":{"type":"failed","reason":"%s"}}}`, status, msg))
+				continue
+			}
+
+			doc["_index"] = action["create"]["_index"]
+			docs = append(docs, doc)
+			items = append(items, `{"create":{"status":201}}`)
+		}
+
+		fmt.Fprintf(w, `{"took":1,"errors":%t,"items":[%s]}`, failed, strings.Join(items, ","))
+		return docs, nil
+	}}
+}
+
+func newElasticsearchConfig(url string) *ElasticsearchConfig {
+	config := DefaultElasticsearchConfig()
+	config.URL = url
+	config.Batch = testBatchConfig()
+	return config
+}
+
+func TestElasticsearchDrinker(t *testing.T) {
+	rec := newBulkRecorder(nil)
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	config := newElasticsearchConfig(srv.URL)
+	config.Username = "marty"
+	config.Password = "outatime"
+	config.Header = http.Header{"X-Opaque-Id": {"lager"}}
+	drinker, err := NewElasticsearchDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	days := []time.Time{
+		time.Date(2015, 10, 21, 23, 59, 0, 0, time.UTC),
+		time.Date(2015, 10, 22, 0, 1, 0, 0, time.UTC),
+	}
+
+	for _, day := range days {
+		logger := NewContextLager(&ContextConfig{
+			Levels:  new(Levels).All(),
+			Drinker: drinker,
+			Clock:   fixedClock(day),
+		})
+		logger.Set("user", "marty").Infof("%s", day.Format("Jan 2"))
+	}
+
+	if err := drinker.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if msgs := rec.messages(); strings.Join(msgs, ",") != "Oct 21,Oct 22" {
+		t.Fatalf("expected both days, got %v", msgs)
+	}
+	if indexes := strings.Join(rec.values("_index"), ","); indexes != "logs-2015.10.21,logs-2015.10.22" {
+		t.Fatalf("expected an index for each day, got %s", indexes)
+	}
+	if rec.logs[0]["user"] != "marty" {
+		t.Fatalf("expected the context fields, got %v", rec.logs[0])
+	}
+
+	if len(rec.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(rec.requests))
+	}
+	req := rec.requests[0]
+	if req.URL.Path != "/_bulk" {
+		t.Fatalf("expected /_bulk, got %s", req.URL.Path)
+	}
+	if user, password, ok := req.BasicAuth(); !ok || user != "marty" || password != "outatime" {
+		t.Fatalf("expected basic auth, got %q", req.Header.Get("Authorization"))
+	}
+	if id := req.Header.Get("X-Opaque-Id"); id != "lager" {
+		t.Fatalf("expected the X-Opaque-Id header, got %q", id)
+	}
+}
+
+func TestElasticsearchDrinkerRetriesFailedItems(t *testing.T) {
+	rec := newBulkRecorder(map[string]int{
+		"rejected": http.StatusTooManyRequests,
+		"invalid":  http.StatusBadRequest,
+	})
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var handled []error
+	config := newElasticsearchConfig(srv.URL)
+	config.Batch.ErrorHandler = func(err error) { handled = append(handled, err) }
+	drinker, err := NewElasticsearchDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	for _, msg := range []string{"first", "rejected", "invalid"} {
+		drinker.Drink(map[string]interface{}{"msg": msg})
+	}
+
+	if err := drinker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(rec.requests))
+	}
+	if msgs := rec.messages(); strings.Join(msgs, ",") != "first,rejected" {
+		t.Fatalf("expected first and rejected, got %v", msgs)
+	}
+	if len(handled) != 1 || !strings.Contains(handled[0].Error(), "dropped 1 logs") {
+		t.Fatalf("expected the invalid log to be dropped, got %v", handled)
+	}
+}
+
+func TestElasticsearchDrinkerClientError(t *testing.T) {
+	rec := newBulkRecorder(map[string]int{"invalid": http.StatusBadRequest})
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	drinker, err := NewElasticsearchDrinker(newElasticsearchConfig(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	testClientError(t, rec, drinker)
+}
+
+func TestElasticsearchDrinkerIndexPrefix(t *testing.T) {
+	rec := newBulkRecorder(nil)
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	config := newElasticsearchConfig(srv.URL)
+	config.IndexPrefix = "service2-v1-"
+	drinker, err := NewElasticsearchDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drinker.DrinkEntry(&Entry{
+		Time:    time.Date(2015, 10, 21, 16, 29, 0, 0, time.UTC),
+		Level:   Info,
+		Message: "hello",
+		Fields:  newFields(nil),
+		Format:  DefaultFormat(),
+	})
+
+	if err := drinker.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if indexes := strings.Join(rec.values("_index"), ","); indexes != "service2-v1-2015.10.21" {
+		t.Fatalf("expected service2-v1-2015.10.21, got %s", indexes)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import "time"
+
+// Entry is a single log as it is handed to an EntryDrinker
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	File    string
+	Func    string
+	Stack   Stack
+	Fields  *Fields
+
+	// Format is how the standard values are logged, DefaultFormat if nil
+	Format *Format
+}
+
+// Standard returns the standard values of the entry, keyed and formatted by
+// its Format, in the order they are logged
+func (e *Entry) Standard() []Field {
+	format := e.Format
+	if format == nil {
+		format = defaultFormat
+	}
+
+	fields := []Field{
+		{Key: format.Keys.Time, Value: format.FormatTime(e.Time)},
+		{Key: format.Keys.Level, Value: format.FormatLevel(e.Level)},
+		{Key: format.Keys.Message, Value: e.Message},
+	}
+
+	if e.File != "" {
+		fields = append(fields, Field{Key: format.Keys.File, Value: e.File})
+	}
+
+	if e.Func != "" {
+		fields = append(fields, Field{Key: format.Keys.Func, Value: e.Func})
+	}
+
+	if len(e.Stack) > 0 {
+		var stack interface{} = e.Stack.String()
+		if format.StructuredStacktraces {
+			stack = e.Stack
+		}
+		fields = append(fields, Field{Key: format.Keys.Stacktrace, Value: stack})
+	}
+
+	return fields
+}
+
+// Map returns the entry as the map handed to Drinker.Drink.
+// Standard values take precedence over context fields with the same key.
+func (e *Entry) Map() map[string]interface{} {
+	v := make(map[string]interface{}, e.Fields.Len()+5)
+	for _, field := range e.Fields.List() {
+		v[field.Key] = field.Value
+	}
+
+	for _, field := range e.Standard() {
+		v[field.Key] = field.Value
+	}
+
+	return v
+}
+
+// entryFromMap creates an entry from the map handed to Drinker.Drink, using
+// the default keys for the standard values. All other values, including
+// textual stacktraces, become context fields.
+func entryFromMap(v map[string]interface{}) *Entry {
+	keys := defaultFormat.Keys
+	e := new(Entry)
+
+	var fields []Field
+	for _, key := range orderedKeys(v) {
+		value := v[key]
+		str, isString := value.(string)
+
+		switch {
+		case key == keys.Time && isString:
+			e.Time, _ = time.Parse(time.RFC3339, str)
+		case key == keys.Level && isString:
+			e.Level = ParseLevel(str)
+		case key == keys.Message && isString:
+			e.Message = str
+		case key == keys.File && isString:
+			e.File = str
+		case key == keys.Func && isString:
+			e.Func = str
+		default:
+			if stack, ok := value.(Stack); ok && key == keys.Stacktrace {
+				e.Stack = stack
+				continue
+			}
+			fields = append(fields, Field{Key: key, Value: value})
+		}
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	e.Fields = new(Fields).with(fields...)
+	return e
+}
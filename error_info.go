@@ -0,0 +1,133 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// maxErrorDepth limits how deep wrapped errors are followed
+const maxErrorDepth = 32
+
+// FieldsError is an error that provides fields to log along with it
+type FieldsError interface {
+	error
+	Fields() map[string]interface{}
+}
+
+// ErrorInfo is the value logged for an error by WithError. JSONDrinker logs it
+// as a nested object, text drinkers log its message.
+type ErrorInfo struct {
+	Message string                 `json:"message"`
+	Type    string                 `json:"type"`
+	Stack   Stack                  `json:"stack,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Causes  []*ErrorInfo           `json:"causes,omitempty"`
+}
+
+// NewErrorInfo creates the ErrorInfo for err, following the errors it wraps
+// with Unwrap() error or, as errors.Join does, Unwrap() []error.
+func NewErrorInfo(err error) *ErrorInfo {
+	return newErrorInfo(err, 0)
+}
+
+func newErrorInfo(err error, depth int) *ErrorInfo {
+	info := &ErrorInfo{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", err),
+		Stack:   errorStack(err),
+	}
+
+	if ferr, ok := err.(FieldsError); ok {
+		info.Fields = ferr.Fields()
+	}
+
+	if depth == maxErrorDepth {
+		return info
+	}
+
+	var causes []error
+	switch err := err.(type) {
+	case interface{ Unwrap() error }:
+		causes = []error{err.Unwrap()}
+	case interface{ Unwrap() []error }:
+		causes = err.Unwrap()
+	}
+
+	for _, cause := range causes {
+		if cause != nil {
+			info.Causes = append(info.Causes, newErrorInfo(cause, depth+1))
+		}
+	}
+
+	return info
+}
+
+func (info *ErrorInfo) String() string {
+	return info.Message
+}
+
+// errorStack returns the stack carried by err, if any. Errors carrying a stack
+// commonly have a Callers() []uintptr method, or a StackTrace() method returning
+// a slice of program counters, as github.com/pkg/errors does.
+func errorStack(err error) Stack {
+	if err, ok := err.(interface{ Callers() []uintptr }); ok {
+		return framesStack(err.Callers(), 0)
+	}
+
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() {
+		return nil
+	}
+
+	typ := method.Type()
+	if typ.NumIn() != 0 || typ.NumOut() != 1 ||
+		typ.Out(0).Kind() != reflect.Slice || typ.Out(0).Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+
+	trace := method.Call(nil)[0]
+	pcs := make([]uintptr, trace.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(trace.Index(i).Uint())
+	}
+
+	return framesStack(pcs, 0)
+}
+
+// ErrorList is the value logged for errors accumulated by WithErrors.
+// JSONDrinker logs it as an array, LogDrinker as keys indexed from 0.
+type ErrorList []*ErrorInfo
+
+// with returns a new ErrorList with errs appended
+func (list ErrorList) with(errs ...error) ErrorList {
+	appended := make(ErrorList, len(list), len(list)+len(errs))
+	copy(appended, list)
+	for _, err := range errs {
+		if err != nil {
+			appended = append(appended, NewErrorInfo(err))
+		}
+	}
+	return appended
+}
+
+func (list ErrorList) String() string {
+	messages := make([]string, len(list))
+	for i, info := range list {
+		messages[i] = info.Message
+	}
+	return strings.Join(messages, "; ")
+}
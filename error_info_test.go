@@ -0,0 +1,174 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// pkgFrame and pkgStack mimic the stacks of github.com/pkg/errors
+type pkgFrame uintptr
+type pkgStack []pkgFrame
+
+type stackError struct {
+	msg string
+	pcs []uintptr
+}
+
+func newStackError(msg string) *stackError {
+	pcs := make([]uintptr, 8)
+	n := runtime.Callers(2, pcs)
+	return &stackError{msg: msg, pcs: pcs[:n]}
+}
+
+func (err *stackError) Error() string {
+	return err.msg
+}
+
+func (err *stackError) StackTrace() pkgStack {
+	stack := make(pkgStack, len(err.pcs))
+	for i, pc := range err.pcs {
+		stack[i] = pkgFrame(pc)
+	}
+	return stack
+}
+
+type fieldsError struct{}
+
+func (fieldsError) Error() string {
+	return "fields"
+}
+
+func (fieldsError) Fields() map[string]interface{} {
+	return map[string]interface{}{"user": "marty"}
+}
+
+func TestNewErrorInfoChain(t *testing.T) {
+	cause := newStackError("cause")
+	err := fmt.Errorf("wrapped: %w", errors.Join(cause, fieldsError{}))
+
+	info := NewErrorInfo(err)
+	if info.Message != err.Error() || info.Type != "*fmt.wrapError" {
+		t.Fatalf("unexpected error info %s %s", info.Message, info.Type)
+	}
+
+	if len(info.Causes) != 1 || len(info.Causes[0].Causes) != 2 {
+		t.Fatalf("expected a joined error with 2 causes, got %+v", info.Causes)
+	}
+
+	stacked := info.Causes[0].Causes[0]
+	if stacked.Message != "cause" || len(stacked.Stack) == 0 {
+		t.Fatalf("expected cause with stack, got %+v", stacked)
+	}
+
+	if stacked.Stack[0].Func != "github.com/doubledutch/lager.TestNewErrorInfoChain" {
+		t.Fatalf("expected stack to start at the test, got %s", stacked.Stack[0].Func)
+	}
+
+	fielded := info.Causes[0].Causes[1]
+	if fielded.Fields["user"] != "marty" {
+		t.Fatalf("expected fields from error, got %v", fielded.Fields)
+	}
+}
+
+func TestErrorInfoDrinkers(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Error),
+		Drinker: NewJSONDrinker(buf),
+	})
+
+	logger.WithError(fmt.Errorf("wrapped: %w", fieldsError{})).Errorf("failed")
+
+	expected := `"error":{"message":"wrapped: fields","type":"*fmt.wrapError","causes":[{"message":"fields","type":"lager.fieldsError","fields":{"user":"marty"}}]}`
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("expected '%s' to contain '%s'", buf.String(), expected)
+	}
+
+	buf.Reset()
+	logger = NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Error),
+		Drinker: NewLogDrinker(buf),
+	})
+
+	logger.WithError(errors.New("failure")).Errorf("failed")
+	if !strings.Contains(buf.String(), "error=failure") {
+		t.Fatalf("expected '%s' to contain error=failure", buf.String())
+	}
+}
+
+func TestContextNamedAndListErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Error),
+		Drinker: NewJSONDrinker(buf),
+	})
+
+	root := errors.New("root")
+	cleanup := errors.New("cleanup")
+
+	logger.WithNamedError("cause", root).WithNamedError("cleanup", cleanup).Errorf("failed")
+
+	for _, expected := range []string{`"cause":{"message":"root"`, `"cleanup":{"message":"cleanup"`} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("expected '%s' to contain '%s'", buf.String(), expected)
+		}
+	}
+
+	buf.Reset()
+	child := logger.WithErrors(root, nil)
+	child.WithErrors(cleanup).Errorf("failed")
+	child.Errorf("failed again")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(lines))
+	}
+
+	expected := `"errors":[{"message":"root","type":"*errors.errorString"},{"message":"cleanup","type":"*errors.errorString"}]`
+	if !strings.Contains(lines[0], expected) {
+		t.Fatalf("expected '%s' to contain '%s'", lines[0], expected)
+	}
+
+	expected = `"errors":[{"message":"root","type":"*errors.errorString"}]`
+	if !strings.Contains(lines[1], expected) {
+		t.Fatalf("expected '%s' to contain '%s'", lines[1], expected)
+	}
+
+	if logger.WithErrors(nil) != logger {
+		t.Fatal("expected WithErrors of only nil errors to return the lager")
+	}
+}
+
+func TestLogDrinkerErrorList(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Error),
+		Drinker: NewLogDrinker(buf),
+	})
+
+	logger.WithErrors(errors.New("root"), errors.New("cleanup")).Errorf("failed")
+
+	if !strings.HasSuffix(buf.String(), "errors.0=root errors.1=cleanup \n") {
+		t.Fatalf("expected indexed errors at the end of '%s'", buf.String())
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"sort"
+	"sync"
+)
+
+// FieldOrder represents the order context fields are logged in,
+// after the standard values
+type FieldOrder uint8
+
+const (
+	// InsertionOrder logs context fields in the order they were first set
+	InsertionOrder FieldOrder = iota
+	// SortedOrder logs context fields sorted by key
+	SortedOrder
+)
+
+// Field is a context key value pair
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields is an ordered set of context fields. Fields are never modified after
+// they are created, so a lager shares them with its children along with any
+// encodings of them that drinkers have cached.
+type Fields struct {
+	list  []Field
+	index map[string]int
+	lazy  bool
+
+	lock    sync.Mutex
+	encoded map[interface{}][]byte
+	sorted  *Fields
+}
+
+// newFields creates Fields from values, ordered by key
+func newFields(values map[string]string) *Fields {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := make([]Field, len(keys))
+	for i, key := range keys {
+		list[i] = Field{Key: key, Value: values[key]}
+	}
+
+	return new(Fields).with(list...)
+}
+
+type byKey []Field
+
+func (fields byKey) Len() int           { return len(fields) }
+func (fields byKey) Swap(i, j int)      { fields[i], fields[j] = fields[j], fields[i] }
+func (fields byKey) Less(i, j int) bool { return fields[i].Key < fields[j].Key }
+
+// Len returns the number of fields
+func (f *Fields) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.list)
+}
+
+// List returns the fields in the order they were first set.
+// The returned slice must not be modified.
+func (f *Fields) List() []Field {
+	if f == nil {
+		return nil
+	}
+	return f.list
+}
+
+// Get returns the value of key and whether it was set
+func (f *Fields) Get(key string) (interface{}, bool) {
+	if f == nil {
+		return nil, false
+	}
+
+	i, ok := f.index[key]
+	if !ok {
+		return nil, false
+	}
+	return f.list[i].Value, true
+}
+
+// Encoded returns the fields encoded by encode. The result is cached under key,
+// usually the drinker itself, so fields shared by many lagers are only encoded
+// once per drinker.
+func (f *Fields) Encoded(key interface{}, encode func([]Field) []byte) []byte {
+	if f == nil {
+		return encode(nil)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if data, ok := f.encoded[key]; ok {
+		return data
+	}
+
+	data := encode(f.list)
+	if f.encoded == nil {
+		f.encoded = make(map[interface{}][]byte)
+	}
+	f.encoded[key] = data
+	return data
+}
+
+// Ordered returns the fields in order. Sorted fields are cached, so their
+// encodings are shared as well.
+func (f *Fields) Ordered(order FieldOrder) *Fields {
+	if f == nil || order != SortedOrder {
+		return f
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.sorted != nil {
+		return f.sorted
+	}
+
+	if sort.IsSorted(byKey(f.list)) {
+		f.sorted = f
+		return f
+	}
+
+	list := make([]Field, len(f.list))
+	copy(list, f.list)
+	sort.Sort(byKey(list))
+
+	f.sorted = new(Fields).with(list...)
+	return f.sorted
+}
+
+// Resolved returns the fields with Valuers evaluated. Fields without Valuers
+// are returned as is, so their cached encodings are kept.
+func (f *Fields) Resolved() *Fields {
+	if f == nil || !f.lazy {
+		return f
+	}
+
+	list := make([]Field, len(f.list))
+	for i, field := range f.list {
+		if valuer, ok := field.Value.(Valuer); ok {
+			field.Value = valuer.Value()
+		}
+		list[i] = field
+	}
+
+	return new(Fields).with(list...)
+}
+
+// with returns new Fields with fields set. A key that is already set keeps its
+// position and takes the new value.
+func (f *Fields) with(fields ...Field) *Fields {
+	list := make([]Field, f.Len(), f.Len()+len(fields))
+	copy(list, f.List())
+
+	index := make(map[string]int, cap(list))
+	for i, field := range list {
+		index[field.Key] = i
+	}
+
+	for _, field := range fields {
+		if i, ok := index[field.Key]; ok {
+			list[i].Value = field.Value
+			continue
+		}
+		index[field.Key] = len(list)
+		list = append(list, field)
+	}
+
+	lazy := false
+	for _, field := range list {
+		if _, ok := field.Value.(Valuer); ok {
+			lazy = true
+			break
+		}
+	}
+
+	return &Fields{
+		list:  list,
+		index: index,
+		lazy:  lazy,
+	}
+}
+
+// without returns new Fields without key
+func (f *Fields) without(key string) *Fields {
+	if _, ok := f.Get(key); !ok {
+		return f
+	}
+
+	list := make([]Field, 0, f.Len()-1)
+	for _, field := range f.List() {
+		if field.Key != key {
+			list = append(list, field)
+		}
+	}
+
+	return new(Fields).with(list...)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import "testing"
+
+func TestFieldsWith(t *testing.T) {
+	fields := newFields(map[string]string{"b": "two", "a": "one"})
+	fields = fields.with(Field{Key: "c", Value: "three"}, Field{Key: "a", Value: "uno"})
+
+	expected := []Field{
+		{Key: "a", Value: "uno"},
+		{Key: "b", Value: "two"},
+		{Key: "c", Value: "three"},
+	}
+
+	if fields.Len() != len(expected) {
+		t.Fatalf("expected %d fields, got %d", len(expected), fields.Len())
+	}
+
+	for i, field := range fields.List() {
+		if field != expected[i] {
+			t.Fatalf("expected %v, got %v", expected[i], field)
+		}
+	}
+
+	fields = fields.without("b")
+	if _, ok := fields.Get("b"); ok {
+		t.Fatal("expected b to be removed")
+	}
+
+	if value, _ := fields.Get("c"); value != "three" {
+		t.Fatalf("expected c == three, got %v", value)
+	}
+}
+
+func TestFieldsEncoded(t *testing.T) {
+	fields := newFields(map[string]string{"a": "one"})
+
+	calls := 0
+	encode := func(list []Field) []byte {
+		calls++
+		return []byte(list[0].Key)
+	}
+
+	for i := 0; i < 3; i++ {
+		if data := fields.Encoded("key", encode); string(data) != "a" {
+			t.Fatalf("expected a, got %s", data)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected fields to be encoded once, got %d", calls)
+	}
+
+	fields.Encoded("other", encode)
+	if calls != 2 {
+		t.Fatalf("expected fields to be encoded once per key, got %d", calls)
+	}
+}
@@ -2,8 +2,11 @@ package lager
 
 import (
 	"fmt"
+	"path"
 	"runtime"
+	"runtime/debug"
 	"strings"
+	"sync"
 )
 
 // FileType represents values for including file information of logs
@@ -26,26 +29,99 @@ func (ft FileType) Caller(calldepth int) string {
 		return ""
 	}
 
-	_, file, line, ok := runtime.Caller(calldepth)
+	frame, ok := callerFrame(calldepth)
 	if !ok {
 		return ""
 	}
 
-	if ft == PackageFile {
-		paths := strings.Split(file, "src/")
-		if len(paths) == 2 {
-			file = paths[1]
+	return ft.Format(frame)
+}
+
+// Format returns the appropriate filename and line number of frame for the file type
+func (ft FileType) Format(frame runtime.Frame) string {
+	if ft == NoFile || frame.File == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:%d", ft.file(frame), frame.Line)
+}
+
+// callerFrame returns the frame calldepth frames above its caller,
+// counting as runtime.Caller does
+func callerFrame(calldepth int) (runtime.Frame, bool) {
+	pcs := make([]uintptr, 1)
+	if runtime.Callers(calldepth+2, pcs) == 0 {
+		return runtime.Frame{}, false
+	}
+
+	frame, _ := runtime.CallersFrames(pcs).Next()
+	return frame, frame.File != ""
+}
+
+// file returns the filename of frame for the file type
+func (ft FileType) file(frame runtime.Frame) string {
+	switch ft {
+	case PackageFile:
+		if pkg := packagePath(frame.Function); pkg != "" {
+			return pkg + "/" + path.Base(frame.File)
 		}
-	} else if ft == ShortFile {
-		short := file
-		for i := len(file) - 1; i > 0; i-- {
-			if file[i] == '/' {
-				short = file[i+1:]
-				break
-			}
+
+		// GOPATH layout, as a fallback for frames without a function
+		paths := strings.Split(frame.File, "src/")
+		if len(paths) == 2 {
+			return paths[1]
 		}
-		file = short
+	case ShortFile:
+		return path.Base(frame.File)
+	}
+
+	return frame.File
+}
+
+// packagePath returns the import path of the package of function, a fully
+// qualified function name as reported by runtime.Frame. The import path does not
+// depend on where the source was built, so it works alike for GOPATH, modules,
+// the module cache and -trimpath builds.
+func packagePath(function string) string {
+	if function == "" {
+		return ""
+	}
+
+	// a package's own name never contains a dot, methods and closures follow it
+	slash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+
+	pkg := function[:slash+1+dot]
+	if i := strings.LastIndex(pkg, "/vendor/"); i >= 0 {
+		pkg = pkg[i+len("/vendor/"):]
 	}
 
-	return fmt.Sprintf("%s:%d", file, line)
+	// dots in the last element of the import path are escaped as %2e
+	pkg = strings.Replace(pkg, "%2e", ".", -1)
+
+	if pkg == "main" {
+		return mainPath()
+	}
+
+	return pkg
+}
+
+var (
+	mainPathOnce  sync.Once
+	mainPathValue string
+)
+
+// mainPath returns the import path of the main package from the build info,
+// or "main" if it is not available
+func mainPath() string {
+	mainPathOnce.Do(func() {
+		mainPathValue = "main"
+		if info, ok := debug.ReadBuildInfo(); ok && info.Path != "" && info.Path != "command-line-arguments" {
+			mainPathValue = info.Path
+		}
+	})
+	return mainPathValue
 }
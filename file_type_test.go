@@ -0,0 +1,59 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPackagePath(t *testing.T) {
+	tests := map[string]string{
+		"github.com/doubledutch/lager.TestPackagePath":                 "github.com/doubledutch/lager",
+		"github.com/doubledutch/lager.(*contextLager).Logf":            "github.com/doubledutch/lager",
+		"github.com/doubledutch/lager/lagertest.NewContextLager.func1": "github.com/doubledutch/lager/lagertest",
+		"gopkg.in/yaml%2ev2.Marshal":                                   "gopkg.in/yaml.v2",
+		"example.com/app/vendor/github.com/doubledutch/lager.Errorf":   "github.com/doubledutch/lager",
+		"example.com/generic.Map[...]":                                 "example.com/generic",
+		"fmt.Sprintf":                                                  "fmt",
+		"":                                                             "",
+	}
+
+	for function, expected := range tests {
+		if actual := packagePath(function); actual != expected {
+			t.Fatalf("%s: expected %s, got %s", function, expected, actual)
+		}
+	}
+}
+
+func TestFileTypeCaller(t *testing.T) {
+	tests := map[FileType]string{
+		ShortFile:   "file_type_test.go:",
+		PackageFile: "github.com/doubledutch/lager/file_type_test.go:",
+	}
+
+	for ft, expected := range tests {
+		if actual := ft.Caller(1); !strings.HasPrefix(actual, expected) {
+			t.Fatalf("expected %s to start with %s", actual, expected)
+		}
+	}
+
+	if actual := FullFile.Caller(1); !strings.HasSuffix(strings.Split(actual, ":")[0], "/file_type_test.go") {
+		t.Fatalf("expected full path to file_type_test.go, got %s", actual)
+	}
+
+	if actual := NoFile.Caller(1); actual != "" {
+		t.Fatalf("expected no file, got %s", actual)
+	}
+}
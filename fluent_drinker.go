@@ -0,0 +1,270 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net"
+	"time"
+)
+
+// FluentConfig is the configuration for FluentDrinker
+type FluentConfig struct {
+	// Network is "tcp" or "unix"
+	Network string
+	// Address is the host:port of the forward input, or the path of its unix socket
+	Address string
+
+	// Tag is the tag of entries
+	Tag string
+	// TagKey is the key of the context field whose value is the tag of an
+	// entry, if set. Entries without it are tagged Tag.
+	TagKey string
+
+	// RequireAck makes sending wait for the server to acknowledge each batch,
+	// resending it otherwise, so entries are delivered at least once
+	RequireAck bool
+	// Timeout is the timeout of connecting, sending and waiting for acks
+	Timeout time.Duration
+
+	Batch BatchConfig
+}
+
+// DefaultFluentConfig creates a default FluentConfig
+func DefaultFluentConfig() *FluentConfig {
+	batch := DefaultBatchConfig()
+	batch.Size = 100
+
+	return &FluentConfig{
+		Network: "tcp",
+		Address: "127.0.0.1:24224",
+		Tag:     "lager",
+		Timeout: 5 * time.Second,
+		Batch:   *batch,
+	}
+}
+
+// FluentDrinker is a Drinker that sends logs to Fluentd or Fluent Bit using
+// the forward protocol. Entries are queued and sent in batches from a
+// background goroutine, as a PackedForward message per tag with the entry's
+// time as the event time. Batches that fail are sent again on a new
+// connection with backoff.
+type FluentDrinker struct {
+	network    string
+	address    string
+	tag        string
+	tagKey     string
+	requireAck bool
+	timeout    time.Duration
+
+	// conn is only used by the batcher's goroutine, and by Close once it
+	// has stopped
+	conn net.Conn
+
+	batcher *batcher
+}
+
+// NewFluentDrinker creates a new Fluent Drinker, connects it to address and
+// starts sending batches
+func NewFluentDrinker(config *FluentConfig) (*FluentDrinker, error) {
+	if config == nil {
+		config = DefaultFluentConfig()
+	}
+
+	drkr := &FluentDrinker{
+		network:    config.Network,
+		address:    config.Address,
+		tag:        config.Tag,
+		tagKey:     config.TagKey,
+		requireAck: config.RequireAck,
+		timeout:    config.Timeout,
+	}
+
+	if err := drkr.dial(); err != nil {
+		return nil, err
+	}
+
+	btchr, err := newBatcher(config.Batch, drkr.send)
+	if err != nil {
+		drkr.conn.Close()
+		return nil, err
+	}
+
+	drkr.batcher = btchr
+	return drkr, nil
+}
+
+// Drink drinks logs, using the default keys for the standard values
+func (drkr *FluentDrinker) Drink(v map[string]interface{}) error {
+	return drkr.DrinkEntry(entryFromMap(v))
+}
+
+// DrinkEntry queues the log to be sent, reusing the cached encoding of the
+// entry's fields
+func (drkr *FluentDrinker) DrinkEntry(e *Entry) error {
+	tag := drkr.tag
+	if drkr.tagKey != "" {
+		if value, ok := e.Fields.Get(drkr.tagKey); ok {
+			if str, ok := value.(string); ok && str != "" {
+				tag = str
+			}
+		}
+	}
+
+	// the time is the event time rather than a value of the record
+	standard := e.Standard()[1:]
+
+	entry := appendMsgpackArrayHeader(nil, 2)
+	entry = appendMsgpackEventTime(entry, e.Time)
+	entry = appendMsgpackMapHeader(entry, len(standard)+e.Fields.Len())
+	for _, field := range standard {
+		entry = appendMsgpackString(entry, field.Key)
+		entry = appendMsgpack(entry, field.Value)
+	}
+	entry = append(entry, e.Fields.Encoded(drkr, drkr.encodeFields)...)
+
+	return drkr.batcher.add(batchItem{key: tag, data: entry})
+}
+
+// encodeFields encodes fields as MessagePack keys and values
+func (drkr *FluentDrinker) encodeFields(fields []Field) []byte {
+	var b []byte
+	for _, field := range fields {
+		b = appendMsgpackString(b, field.Key)
+		b = appendMsgpack(b, field.Value)
+	}
+	return b
+}
+
+// Flush sends the queued logs
+func (drkr *FluentDrinker) Flush() error {
+	return drkr.batcher.flush()
+}
+
+// Close sends the queued logs, stops sending and closes the connection.
+// Logs drunk after it are dropped.
+func (drkr *FluentDrinker) Close() error {
+	err := drkr.batcher.close()
+	if drkr.conn != nil {
+		drkr.conn.Close()
+		drkr.conn = nil
+	}
+	return err
+}
+
+// send sends items as a PackedForward message per tag, returning those of the
+// tags that were not sent, or not acknowledged if acks are required
+func (drkr *FluentDrinker) send(items []batchItem) ([]batchItem, error) {
+	var tags []string
+	batches := make(map[string][]batchItem)
+	for _, item := range items {
+		if _, ok := batches[item.key]; !ok {
+			tags = append(tags, item.key)
+		}
+		batches[item.key] = append(batches[item.key], item)
+	}
+
+	var retry []batchItem
+	var err error
+	for _, tag := range tags {
+		// once the server cannot be reached, the other tags are not tried
+		if err == nil {
+			err = drkr.sendTag(tag, batches[tag])
+		}
+
+		if err != nil {
+			retry = append(retry, batches[tag]...)
+		}
+	}
+
+	return retry, err
+}
+
+// sendTag sends the items of tag as a PackedForward message, reconnecting
+// if there is no connection. The connection is closed if sending fails.
+func (drkr *FluentDrinker) sendTag(tag string, items []batchItem) error {
+	var entries []byte
+	for _, item := range items {
+		entries = append(entries, item.data...)
+	}
+
+	option := map[string]interface{}{"size": len(items)}
+
+	var chunk string
+	if drkr.requireAck {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+
+		chunk = base64.StdEncoding.EncodeToString(id)
+		option["chunk"] = chunk
+	}
+
+	msg := appendMsgpackArrayHeader(nil, 3)
+	msg = appendMsgpackString(msg, tag)
+	msg = appendMsgpackBin(msg, entries)
+	msg = appendMsgpack(msg, option)
+
+	if drkr.conn == nil {
+		if err := drkr.dial(); err != nil {
+			return err
+		}
+	}
+
+	if err := drkr.write(msg, chunk); err != nil {
+		drkr.conn.Close()
+		drkr.conn = nil
+		return err
+	}
+	return nil
+}
+
+// write writes msg and waits for the ack of chunk, if set
+func (drkr *FluentDrinker) write(msg []byte, chunk string) error {
+	if drkr.timeout > 0 {
+		drkr.conn.SetDeadline(time.Now().Add(drkr.timeout))
+	}
+
+	if _, err := drkr.conn.Write(msg); err != nil {
+		return err
+	}
+
+	if chunk == "" {
+		return nil
+	}
+
+	resp, err := readMsgpack(drkr.conn)
+	if err != nil {
+		return err
+	}
+
+	if ack, _ := resp.(map[string]interface{}); ack == nil || ack["ack"] != chunk {
+		return errors.New("fluent: unexpected ack")
+	}
+	return nil
+}
+
+// dial connects to the server
+func (drkr *FluentDrinker) dial() error {
+	conn, err := net.DialTimeout(drkr.network, drkr.address, drkr.timeout)
+	if err != nil {
+		return err
+	}
+
+	drkr.conn = conn
+	return nil
+}
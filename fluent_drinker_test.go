@@ -0,0 +1,292 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// fluentMessage is a PackedForward message received by fluentServer
+type fluentMessage struct {
+	Tag     string
+	Records []map[string]interface{}
+	Option  map[string]interface{}
+}
+
+// fluentServer accepts connections one at a time and sends the messages
+// received on them to messages. It acks messages with a chunk unless dropAcks
+// is positive, in which case it closes the connection instead and decrements it.
+type fluentServer struct {
+	ln       net.Listener
+	messages chan fluentMessage
+	dropAcks int
+}
+
+func newFluentServer(t *testing.T, dropAcks int) *fluentServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	srv := &fluentServer{
+		ln:       ln,
+		messages: make(chan fluentMessage, 100),
+		dropAcks: dropAcks,
+	}
+	go srv.serve(t)
+	return srv
+}
+
+func (srv *fluentServer) serve(t *testing.T) {
+	for {
+		conn, err := srv.ln.Accept()
+		if err != nil {
+			return
+		}
+		srv.handle(t, conn)
+	}
+}
+
+func (srv *fluentServer) handle(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		v, err := readMsgpack(r)
+		if err != nil {
+			return
+		}
+
+		msg := v.([]interface{})
+		entries := bytes.NewReader(msg[1].([]byte))
+		option := msg[2].(map[string]interface{})
+
+		var records []map[string]interface{}
+		for entries.Len() > 0 {
+			entry, err := readMsgpack(entries)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			pair := entry.([]interface{})
+			if ext, ok := pair[0].(msgpackExt); !ok || ext.Type != 0 {
+				t.Errorf("expected an EventTime, got %#v", pair[0])
+			}
+			records = append(records, pair[1].(map[string]interface{}))
+		}
+
+		if chunk, ok := option["chunk"]; ok {
+			if srv.dropAcks > 0 {
+				srv.dropAcks--
+				return
+			}
+			conn.Write(appendMsgpack(nil, map[string]interface{}{"ack": chunk}))
+		}
+
+		srv.messages <- fluentMessage{Tag: msg[0].(string), Records: records, Option: option}
+	}
+}
+
+func (srv *fluentServer) receive(t *testing.T) fluentMessage {
+	select {
+	case msg := <-srv.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a message")
+		return fluentMessage{}
+	}
+}
+
+func newFluentConfig(srv *fluentServer) *FluentConfig {
+	config := DefaultFluentConfig()
+	config.Address = srv.ln.Addr().String()
+	config.Batch.Interval = 0
+	config.Batch.MinBackoff = time.Millisecond
+	return config
+}
+
+func TestFluentDrinker(t *testing.T) {
+	srv := newFluentServer(t, 0)
+
+	config := newFluentConfig(srv)
+	config.Batch.Size = 2
+	drinker, err := NewFluentDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	logger := newTestLager(t, drinker)
+
+	logger.Set("user", "marty").Infof("first")
+	logger.Warnf("second")
+
+	msg := srv.receive(t)
+	if msg.Tag != "lager" || len(msg.Records) != 2 || msg.Option["size"] != int64(2) {
+		t.Fatalf("expected a batch of 2 tagged lager, got %+v", msg)
+	}
+
+	first, second := msg.Records[0], msg.Records[1]
+	if first["msg"] != "first" || first["level"] != "Info" || first["user"] != "marty" {
+		t.Fatalf("unexpected first record %v", first)
+	}
+	if second["msg"] != "second" || second["level"] != "Warn" {
+		t.Fatalf("unexpected second record %v", second)
+	}
+	if _, ok := first["time"]; ok {
+		t.Fatalf("expected the time to be the event time, got %v", first)
+	}
+}
+
+func TestFluentDrinkerTagKey(t *testing.T) {
+	srv := newFluentServer(t, 0)
+
+	config := newFluentConfig(srv)
+	config.TagKey = "service"
+	drinker, err := NewFluentDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	logger := newTestLager(t, drinker)
+
+	logger.Child().Set("service", "billing").Infof("one")
+	logger.Infof("two")
+	logger.Child().Set("service", "billing").Infof("three")
+
+	if err := drinker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	billing, untagged := srv.receive(t), srv.receive(t)
+	if billing.Tag != "billing" || len(billing.Records) != 2 {
+		t.Fatalf("expected 2 records tagged billing, got %+v", billing)
+	}
+	if untagged.Tag != "lager" || len(untagged.Records) != 1 || untagged.Records[0]["msg"] != "two" {
+		t.Fatalf("expected 1 record tagged lager, got %+v", untagged)
+	}
+}
+
+func TestFluentDrinkerAck(t *testing.T) {
+	srv := newFluentServer(t, 2)
+
+	config := newFluentConfig(srv)
+	config.Batch.Size = 1
+	config.RequireAck = true
+	drinker, err := NewFluentDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	if err := drinker.Drink(map[string]interface{}{"msg": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := srv.receive(t)
+	if msg.Records[0]["msg"] != "hello" || msg.Option["chunk"] == nil {
+		t.Fatalf("expected the acked record, got %+v", msg)
+	}
+}
+
+func TestFluentDrinkerDropsAfterRetries(t *testing.T) {
+	srv := newFluentServer(t, 10)
+
+	config := newFluentConfig(srv)
+	config.RequireAck = true
+	config.Batch.MaxRetries = 2
+	drinker, err := NewFluentDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	drinker.Drink(map[string]interface{}{"msg": "hello"})
+
+	if err := drinker.Flush(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestFluentDrinkerFlushInterval(t *testing.T) {
+	srv := newFluentServer(t, 0)
+
+	config := newFluentConfig(srv)
+	config.Batch.Interval = 10 * time.Millisecond
+	drinker, err := NewFluentDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	drinker.Drink(map[string]interface{}{"msg": "hello"})
+
+	if msg := srv.receive(t); msg.Records[0]["msg"] != "hello" {
+		t.Fatalf("expected the record, got %+v", msg)
+	}
+}
+
+func TestFluentDrinkerDoesNotBlock(t *testing.T) {
+	// the server never acks, so sending blocks until the timeout
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	config := DefaultFluentConfig()
+	config.Address = ln.Addr().String()
+	config.RequireAck = true
+	config.Timeout = 100 * time.Millisecond
+	config.Batch.Size = 1
+	config.Batch.QueueSize = 1
+	config.Batch.MaxRetries = 0
+	drinker, err := NewFluentDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	start := time.Now()
+	for i := 0; ; i++ {
+		err := drinker.Drink(map[string]interface{}{"msg": "hello"})
+		if err == ErrQueueFull {
+			break
+		}
+		if err != nil || i == 2 {
+			t.Fatalf("expected ErrQueueFull, got %v", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed >= config.Timeout {
+		t.Fatalf("expected drinking not to wait for the server, took %s", elapsed)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// UnixSeconds is a TimeLayout that logs times as seconds since the Unix epoch
+	UnixSeconds = "unix"
+	// UnixMillis is a TimeLayout that logs times as milliseconds since the Unix epoch
+	UnixMillis = "unixmilli"
+	// UnixNanos is a TimeLayout that logs times as nanoseconds since the Unix epoch
+	UnixNanos = "unixnano"
+)
+
+// Keys are the keys used for the standard values of a log
+type Keys struct {
+	Time       string
+	Level      string
+	Message    string
+	File       string
+	Func       string
+	Stacktrace string
+	Error      string
+	Errors     string
+}
+
+// Format defines the keys and formatting of the standard values of a log
+type Format struct {
+	Keys Keys
+
+	// TimeLayout is the layout passed to time.Format,
+	// or one of UnixSeconds, UnixMillis or UnixNanos.
+	TimeLayout string
+	// LocalTime formats times in the local time zone instead of UTC
+	LocalTime bool
+	// LowerCaseLevels formats levels in lower case, such as "error"
+	LowerCaseLevels bool
+	// StructuredStacktraces logs stacktraces as a list of frames instead of text
+	StructuredStacktraces bool
+}
+
+// DefaultFormat creates the default Format
+func DefaultFormat() *Format {
+	return &Format{
+		Keys: Keys{
+			Time:       "time",
+			Level:      "level",
+			Message:    "msg",
+			File:       "file",
+			Func:       "func",
+			Stacktrace: "stacktrace",
+			Error:      "error",
+			Errors:     "errors",
+		},
+		TimeLayout: time.RFC3339,
+	}
+}
+
+var defaultFormat = DefaultFormat()
+
+// withDefaults returns a copy of f with its empty keys and time layout set to
+// those of DefaultFormat, or DefaultFormat if f is nil
+func (f *Format) withDefaults() *Format {
+	if f == nil {
+		return DefaultFormat()
+	}
+
+	format := *f
+	keys, defaults := &format.Keys, defaultFormat.Keys
+
+	setDefault := func(key *string, value string) {
+		if *key == "" {
+			*key = value
+		}
+	}
+
+	setDefault(&keys.Time, defaults.Time)
+	setDefault(&keys.Level, defaults.Level)
+	setDefault(&keys.Message, defaults.Message)
+	setDefault(&keys.File, defaults.File)
+	setDefault(&keys.Func, defaults.Func)
+	setDefault(&keys.Stacktrace, defaults.Stacktrace)
+	setDefault(&keys.Error, defaults.Error)
+	setDefault(&keys.Errors, defaults.Errors)
+	setDefault(&format.TimeLayout, defaultFormat.TimeLayout)
+
+	return &format
+}
+
+// FormatTime formats t as a string, or as an int64 for the Unix layouts
+func (f *Format) FormatTime(t time.Time) interface{} {
+	switch f.TimeLayout {
+	case UnixSeconds:
+		return t.Unix()
+	case UnixMillis:
+		return t.UnixNano() / int64(time.Millisecond)
+	case UnixNanos:
+		return t.UnixNano()
+	}
+
+	if f.LocalTime {
+		t = t.Local()
+	} else {
+		t = t.UTC()
+	}
+	return t.Format(f.TimeLayout)
+}
+
+// FormatLevel formats lvl
+func (f *Format) FormatLevel(lvl Level) string {
+	if f.LowerCaseLevels {
+		return strings.ToLower(lvl.String())
+	}
+	return lvl.String()
+}
+
+// standardKeys returns the keys of the standard values in the order they are logged
+func (f *Format) standardKeys() []string {
+	return []string{f.Keys.Time, f.Keys.Level, f.Keys.Message, f.Keys.File, f.Keys.Func, f.Keys.Stacktrace}
+}
+
+// reserved returns whether key is used for a standard value
+func (f *Format) reserved(key string) bool {
+	for _, standard := range f.standardKeys() {
+		if key == standard {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	now := time.Date(2015, 10, 21, 16, 29, 0, 123456789, time.FixedZone("PDT", -7*60*60))
+
+	tests := []struct {
+		layout   string
+		expected interface{}
+	}{
+		{time.RFC3339, "2015-10-21T23:29:00Z"},
+		{time.RFC3339Nano, "2015-10-21T23:29:00.123456789Z"},
+		{"2006-01-02T15:04:05.000Z07:00", "2015-10-21T23:29:00.123Z"},
+		{UnixSeconds, int64(1445470140)},
+		{UnixMillis, int64(1445470140123)},
+		{UnixNanos, int64(1445470140123456789)},
+	}
+
+	for _, test := range tests {
+		format := DefaultFormat()
+		format.TimeLayout = test.layout
+
+		if actual := format.FormatTime(now); actual != test.expected {
+			t.Fatalf("layout %s: expected %v, got %v", test.layout, test.expected, actual)
+		}
+	}
+}
+
+func TestFormatLevel(t *testing.T) {
+	format := DefaultFormat()
+	if actual := format.FormatLevel(Warn); actual != "Warn" {
+		t.Fatalf("expected Warn, got %s", actual)
+	}
+
+	format.LowerCaseLevels = true
+	if actual := format.FormatLevel(Warn); actual != "warn" {
+		t.Fatalf("expected warn, got %s", actual)
+	}
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// GELFCompression represents how GELFDrinker compresses messages sent over UDP
+type GELFCompression uint8
+
+const (
+	// GELFGzip compresses messages with gzip
+	GELFGzip GELFCompression = iota
+	// GELFZlib compresses messages with zlib
+	GELFZlib
+	// GELFNoCompression does not compress messages
+	GELFNoCompression
+)
+
+const (
+	// gelfMaxChunks is the maximum number of chunks of a message
+	gelfMaxChunks = 128
+	// gelfChunkHeader is the size of the header of each chunk
+	gelfChunkHeader = 12
+)
+
+// GELFConfig is the configuration for GELFDrinker
+type GELFConfig struct {
+	// Network is "udp" or "tcp"
+	Network string
+	// Address is the host:port of the GELF input
+	Address string
+
+	// Host identifies the machine, os.Hostname if empty
+	Host string
+
+	// Compression is how messages sent over UDP are compressed.
+	// Messages sent over TCP are never compressed.
+	Compression GELFCompression
+	// ChunkSize is the maximum size of a UDP datagram. Larger messages are
+	// split in chunks.
+	ChunkSize int
+}
+
+// DefaultGELFConfig creates a default GELFConfig
+func DefaultGELFConfig() *GELFConfig {
+	return &GELFConfig{
+		Network:     "udp",
+		Address:     "127.0.0.1:12201",
+		Compression: GELFGzip,
+		ChunkSize:   1420,
+	}
+}
+
+// GELFDrinker is a Drinker that sends logs to Graylog as GELF 1.1 messages,
+// over UDP with compression and chunking, or over TCP delimited by null bytes.
+// The message is sent as short_message, along with the stacktrace as
+// full_message, and context fields as additional fields prefixed with '_'.
+// The connection is reestablished if sending fails.
+type GELFDrinker struct {
+	network string
+	address string
+
+	host        string
+	compression GELFCompression
+	chunkSize   int
+
+	lock sync.Mutex
+	conn net.Conn
+}
+
+// NewGELFDrinker creates a new GELF Drinker and connects it to address
+func NewGELFDrinker(config *GELFConfig) (*GELFDrinker, error) {
+	if config == nil {
+		config = DefaultGELFConfig()
+	}
+
+	drkr := &GELFDrinker{
+		network:     config.Network,
+		address:     config.Address,
+		host:        config.Host,
+		compression: config.Compression,
+		chunkSize:   config.ChunkSize,
+	}
+
+	if drkr.host == "" {
+		drkr.host, _ = os.Hostname()
+	}
+
+	if drkr.chunkSize <= gelfChunkHeader {
+		drkr.chunkSize = DefaultGELFConfig().ChunkSize
+	}
+
+	conn, err := net.Dial(drkr.network, drkr.address)
+	if err != nil {
+		return nil, err
+	}
+
+	drkr.conn = conn
+	return drkr, nil
+}
+
+// Drink drinks logs, using the default keys for the standard values
+func (drkr *GELFDrinker) Drink(v map[string]interface{}) error {
+	return drkr.DrinkEntry(entryFromMap(v))
+}
+
+// DrinkEntry drinks logs, reusing the cached encoding of the entry's fields
+func (drkr *GELFDrinker) DrinkEntry(e *Entry) error {
+	format := e.Format
+	if format == nil {
+		format = defaultFormat
+	}
+
+	severity, ok := syslogSeverities[e.Level]
+	if !ok {
+		severity = syslogSeverities[Error]
+	}
+
+	members := []Field{
+		{Key: "version", Value: "1.1"},
+		{Key: "host", Value: drkr.host},
+		{Key: "short_message", Value: e.Message},
+	}
+
+	if len(e.Stack) > 0 {
+		members = append(members, Field{Key: "full_message", Value: e.Message + "\n" + e.Stack.String()})
+	}
+
+	members = append(members,
+		Field{Key: "timestamp", Value: float64(e.Time.UnixNano()/int64(1e6)) / 1e3},
+		Field{Key: "level", Value: severity},
+	)
+
+	if e.File != "" {
+		members = append(members, Field{Key: gelfName(format.Keys.File), Value: e.File})
+	}
+
+	if e.Func != "" {
+		members = append(members, Field{Key: gelfName(format.Keys.Func), Value: e.Func})
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteByte('{')
+
+	for i, member := range members {
+		data, err := jsonMember(member.Key, member.Value)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(data)
+	}
+
+	b.Write(e.Fields.Encoded(drkr, drkr.encodeFields))
+	b.WriteByte('}')
+
+	return drkr.send(b.Bytes())
+}
+
+// Close closes the connection
+func (drkr *GELFDrinker) Close() error {
+	drkr.lock.Lock()
+	defer drkr.lock.Unlock()
+
+	if drkr.conn == nil {
+		return nil
+	}
+
+	err := drkr.conn.Close()
+	drkr.conn = nil
+	return err
+}
+
+// encodeFields encodes fields as additional fields, each preceded by a comma.
+// Numbers are sent as is and all other values as strings.
+func (drkr *GELFDrinker) encodeFields(fields []Field) []byte {
+	b := new(bytes.Buffer)
+	for _, field := range fields {
+		if list, ok := field.Value.(ErrorList); ok {
+			for i, info := range list {
+				drkr.appendField(b, fmt.Sprintf("%s_%d", field.Key, i), info)
+			}
+			continue
+		}
+
+		drkr.appendField(b, field.Key, field.Value)
+	}
+	return b.Bytes()
+}
+
+func (drkr *GELFDrinker) appendField(b *bytes.Buffer, key string, value interface{}) {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+	default:
+		value = fmt.Sprint(value)
+	}
+
+	data, err := jsonMember(gelfName(key), value)
+	if err != nil {
+		data, _ = jsonMember(gelfName(key), fmt.Sprint(value))
+	}
+
+	b.WriteByte(',')
+	b.Write(data)
+}
+
+// gelfName returns key as the name of an additional field: '_' followed by
+// word characters, '.' and '-', with all others replaced by '_'. The reserved
+// name "_id" becomes "__id".
+func gelfName(key string) string {
+	b := new(strings.Builder)
+	b.WriteByte('_')
+
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			r == '_' || r == '.' || r == '-') {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+
+	if b.String() == "_id" {
+		return "__id"
+	}
+	return b.String()
+}
+
+// send sends msg, reconnecting and sending it again once if it fails
+func (drkr *GELFDrinker) send(msg []byte) error {
+	var datagrams [][]byte
+	if strings.HasPrefix(drkr.network, "tcp") {
+		datagrams = [][]byte{append(msg, 0)}
+	} else {
+		compressed, err := drkr.compress(msg)
+		if err != nil {
+			return err
+		}
+
+		if datagrams, err = drkr.chunk(compressed); err != nil {
+			return err
+		}
+	}
+
+	drkr.lock.Lock()
+	defer drkr.lock.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if drkr.conn == nil {
+			if drkr.conn, err = net.Dial(drkr.network, drkr.address); err != nil {
+				continue
+			}
+		}
+
+		if err = writeDatagrams(drkr.conn, datagrams); err == nil {
+			return nil
+		}
+
+		drkr.conn.Close()
+		drkr.conn = nil
+	}
+
+	return err
+}
+
+// writeDatagrams writes each datagram to conn
+func writeDatagrams(conn net.Conn, datagrams [][]byte) error {
+	for _, datagram := range datagrams {
+		if _, err := conn.Write(datagram); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compress compresses msg with the drinker's compression
+func (drkr *GELFDrinker) compress(msg []byte) ([]byte, error) {
+	b := new(bytes.Buffer)
+
+	var w io.WriteCloser
+
+	switch drkr.compression {
+	case GELFGzip:
+		w = gzip.NewWriter(b)
+	case GELFZlib:
+		w = zlib.NewWriter(b)
+	default:
+		return msg, nil
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// chunk splits msg in chunks that fit the chunk size, if it does not fit it
+func (drkr *GELFDrinker) chunk(msg []byte) ([][]byte, error) {
+	if len(msg) <= drkr.chunkSize {
+		return [][]byte{msg}, nil
+	}
+
+	size := drkr.chunkSize - gelfChunkHeader
+	count := (len(msg) + size - 1) / size
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("gelf: message of %d bytes needs more than %d chunks", len(msg), gelfMaxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		chunk := make([]byte, 0, gelfChunkHeader+end-i*size)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunks = append(chunks, append(chunk, msg[i*size:end]...))
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newGELFLager(t *testing.T, config *GELFConfig) ContextLager {
+	config.Host = "hill-valley"
+
+	drinker, err := NewGELFDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestLager(t, drinker)
+}
+
+func newGELFListener(t *testing.T, compression GELFCompression) (net.PacketConn, *GELFConfig) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	config := DefaultGELFConfig()
+	config.Address = conn.LocalAddr().String()
+	config.Compression = compression
+	return conn, config
+}
+
+// readGELF reads a message sent to conn, reassembling chunks and decompressing it
+func readGELF(t *testing.T, conn net.PacketConn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg []byte
+	var chunks [][]byte
+	for {
+		buf := make([]byte, 64*1024)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		datagram := buf[:n]
+
+		if !bytes.HasPrefix(datagram, []byte{0x1e, 0x0f}) {
+			msg = datagram
+			break
+		}
+
+		seq, count := int(datagram[10]), int(datagram[11])
+		if chunks == nil {
+			chunks = make([][]byte, count)
+		}
+		chunks[seq] = datagram[gelfChunkHeader:]
+
+		if seq == count-1 {
+			msg = bytes.Join(chunks, nil)
+			break
+		}
+	}
+
+	var r io.Reader = bytes.NewReader(msg)
+	switch {
+	case bytes.HasPrefix(msg, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case msg[0] == 0x78:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+
+	v := make(map[string]interface{})
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestGELFDrinker(t *testing.T) {
+	for _, compression := range []GELFCompression{GELFGzip, GELFZlib, GELFNoCompression} {
+		conn, config := newGELFListener(t, compression)
+		logger := newGELFLager(t, config)
+
+		logger.Set("user", "marty mcfly").Set("id", "1").WithErrors(errors.New("late")).Warnf("hello")
+
+		v := readGELF(t, conn)
+		expected := map[string]interface{}{
+			"version":       "1.1",
+			"host":          "hill-valley",
+			"short_message": "hello",
+			"timestamp":     1445444940.123,
+			"level":         4.0,
+			"_user":         "marty mcfly",
+			"__id":          "1",
+			"_errors_0":     "late",
+		}
+
+		if len(v) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, v)
+		}
+		for key, value := range expected {
+			if v[key] != value {
+				t.Fatalf("expected %s to be %v, got %v", key, value, v[key])
+			}
+		}
+	}
+}
+
+func TestGELFDrinkerStacktrace(t *testing.T) {
+	conn, config := newGELFListener(t, GELFGzip)
+	drinker, err := NewGELFDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:      new(Levels).All(),
+		Drinker:     drinker,
+		Stacktraces: true,
+	})
+
+	logger.Errorf("failed")
+
+	v := readGELF(t, conn)
+	full, _ := v["full_message"].(string)
+	if !strings.HasPrefix(full, "failed\n") || !strings.Contains(full, "TestGELFDrinkerStacktrace") {
+		t.Fatalf("expected full_message with the stacktrace, got %q", full)
+	}
+}
+
+func TestGELFDrinkerChunking(t *testing.T) {
+	conn, config := newGELFListener(t, GELFNoCompression)
+	config.ChunkSize = 512
+	logger := newGELFLager(t, config)
+
+	large := strings.Repeat("lager ", 1000)
+	logger.Set("large", large).Infof("large")
+
+	v := readGELF(t, conn)
+	if v["_large"] != large {
+		t.Fatalf("expected large field, got %v", v["_large"])
+	}
+}
+
+func TestGELFDrinkerTooManyChunks(t *testing.T) {
+	_, config := newGELFListener(t, GELFNoCompression)
+	config.ChunkSize = 100
+
+	drinker, err := NewGELFDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	err = drinker.Drink(map[string]interface{}{
+		"msg":   "large",
+		"large": strings.Repeat("x", 128*100),
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestGELFDrinkerTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	config := DefaultGELFConfig()
+	config.Network = "tcp"
+	config.Address = ln.Addr().String()
+	logger := newGELFLager(t, config)
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	logger.Infof("first")
+	logger.Infof("second")
+
+	r := bufio.NewReader(conn)
+	for _, expected := range []string{"first", "second"} {
+		msg, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := make(map[string]interface{})
+		if err := json.Unmarshal(msg[:len(msg)-1], &v); err != nil {
+			t.Fatal(err)
+		}
+		if v["short_message"] != expected {
+			t.Fatalf("expected %s, got %v", expected, v["short_message"])
+		}
+	}
+}
+
+func TestGELFName(t *testing.T) {
+	names := map[string]string{
+		"user":      "_user",
+		"user.id":   "_user.id",
+		"a b/c":     "_a_b_c",
+		"id":        "__id",
+		"trace-id":  "_trace-id",
+		"_internal": "__internal",
+	}
+
+	for key, expected := range names {
+		if actual := gelfName(key); actual != expected {
+			t.Fatalf("expected '%s' for '%s', got '%s'", expected, key, actual)
+		}
+	}
+}
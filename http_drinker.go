@@ -0,0 +1,236 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPEncoding represents how HTTPDrinker encodes batches of entries
+type HTTPEncoding uint8
+
+const (
+	// NDJSON encodes batches as newline delimited JSON objects
+	NDJSON HTTPEncoding = iota
+	// JSONArray encodes batches as a JSON array of objects
+	JSONArray
+)
+
+// HTTPConfig is the configuration for HTTPDrinker
+type HTTPConfig struct {
+	// URL is where batches are posted
+	URL string
+	// Client posts batches, a client with a 30 second timeout if nil
+	Client *http.Client
+	// Header is added to each request
+	Header http.Header
+
+	Encoding HTTPEncoding
+	// Gzip compresses requests with gzip
+	Gzip bool
+
+	Batch BatchConfig
+}
+
+// DefaultHTTPConfig creates a default HTTPConfig
+func DefaultHTTPConfig() *HTTPConfig {
+	return &HTTPConfig{
+		Encoding: NDJSON,
+		Gzip:     true,
+		Batch:    *DefaultBatchConfig(),
+	}
+}
+
+// HTTPDrinker is a Drinker that posts logs to an HTTP endpoint in batches,
+// encoded as JSON. Batches that fail with a network error, a 429 or 5xx
+// status are sent again with backoff.
+type HTTPDrinker struct {
+	url      string
+	client   *http.Client
+	header   http.Header
+	encoding HTTPEncoding
+	gzip     bool
+
+	batcher *batcher
+}
+
+// NewHTTPDrinker creates a new HTTP Drinker and starts sending batches
+func NewHTTPDrinker(config *HTTPConfig) (*HTTPDrinker, error) {
+	if config == nil {
+		config = DefaultHTTPConfig()
+	}
+
+	if err := checkHTTPURL(config.URL); err != nil {
+		return nil, err
+	}
+
+	drkr := &HTTPDrinker{
+		url:      config.URL,
+		client:   config.Client,
+		header:   config.Header,
+		encoding: config.Encoding,
+		gzip:     config.Gzip,
+	}
+
+	if drkr.client == nil {
+		drkr.client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	btchr, err := newBatcher(config.Batch, drkr.send)
+	if err != nil {
+		return nil, err
+	}
+
+	drkr.batcher = btchr
+	return drkr, nil
+}
+
+// checkHTTPURL returns an error if rawURL is not an http or https URL
+func checkHTTPURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: the scheme must be http or https", rawURL)
+	}
+	return nil
+}
+
+// Drink drinks logs, using the default keys for the standard values
+func (drkr *HTTPDrinker) Drink(v map[string]interface{}) error {
+	return drkr.DrinkEntry(entryFromMap(v))
+}
+
+// DrinkEntry queues the log to be sent, reusing the cached JSON encoding of
+// the entry's fields
+func (drkr *HTTPDrinker) DrinkEntry(e *Entry) error {
+	b := new(bytes.Buffer)
+	if err := appendJSONEntry(b, e, drkr); err != nil {
+		return err
+	}
+
+	return drkr.batcher.add(batchItem{data: b.Bytes()})
+}
+
+// Flush sends the queued logs
+func (drkr *HTTPDrinker) Flush() error {
+	return drkr.batcher.flush()
+}
+
+// Close sends the queued logs and stops sending. Logs drunk after it are
+// dropped.
+func (drkr *HTTPDrinker) Close() error {
+	return drkr.batcher.close()
+}
+
+// send posts items, returning them if they should be sent again
+func (drkr *HTTPDrinker) send(items []batchItem) ([]batchItem, error) {
+	b := new(bytes.Buffer)
+
+	if drkr.encoding == JSONArray {
+		b.WriteByte('[')
+		for i, item := range items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.Write(item.data)
+		}
+		b.WriteByte(']')
+	} else {
+		for _, item := range items {
+			b.Write(item.data)
+			b.WriteByte('\n')
+		}
+	}
+
+	contentType := "application/x-ndjson"
+	if drkr.encoding == JSONArray {
+		contentType = "application/json"
+	}
+
+	resp, err := httpPost(drkr.client, drkr.url, drkr.header, contentType, b.Bytes(), drkr.gzip)
+	if err != nil {
+		return items, err
+	}
+	discardBody(resp)
+
+	if err := httpStatusError(resp); err != nil {
+		if httpRetryable(resp.StatusCode) {
+			return items, err
+		}
+		return nil, fmt.Errorf("dropped %d logs: %v", len(items), err)
+	}
+	return nil, nil
+}
+
+// httpPost posts body, compressed with gzip if compress is set, with header
+// and the content type
+func httpPost(client *http.Client, url string, header http.Header, contentType string, body []byte, compress bool) (*http.Response, error) {
+	if compress {
+		b := new(bytes.Buffer)
+		w := gzip.NewWriter(b)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		body = b.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	return client.Do(req)
+}
+
+// httpStatusError returns an error if resp does not have a 2xx status
+func httpStatusError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return fmt.Errorf("%s %s: %s", resp.Request.Method, resp.Request.URL, resp.Status)
+}
+
+// httpRetryable returns whether a request that failed with status should be
+// sent again
+func httpRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// discardBody reads the rest of resp's body and closes it, so its connection
+// can be reused
+func discardBody(resp *http.Response) {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
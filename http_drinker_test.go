@@ -0,0 +1,349 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpRecorder records the logs posted to it, failing requests with the
+// statuses in fail first. Bodies are decoded with decode if set, which writes
+// the response, and as NDJSON or a JSON array otherwise.
+type httpRecorder struct {
+	lock     sync.Mutex
+	requests []*http.Request
+	logs     []map[string]interface{}
+	fail     []int
+	decode   func(w http.ResponseWriter, body []byte) ([]map[string]interface{}, error)
+}
+
+func (rec *httpRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	rec.requests = append(rec.requests, r)
+	if len(rec.fail) > 0 {
+		w.WriteHeader(rec.fail[0])
+		rec.fail = rec.fail[1:]
+		return
+	}
+
+	body, err := readRequestBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	decode := rec.decode
+	if decode == nil {
+		decode = decodeJSONLogs(r.Header.Get("Content-Type"))
+	}
+
+	logs, err := decode(w, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec.logs = append(rec.logs, logs...)
+}
+
+// readRequestBody reads the body of r, decompressing it if it is gzipped
+func readRequestBody(r *http.Request) ([]byte, error) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = gz
+	}
+
+	return io.ReadAll(body)
+}
+
+// decodeJSONLogs decodes logs posted as a JSON array if contentType is
+// application/json, and as NDJSON otherwise
+func decodeJSONLogs(contentType string) func(http.ResponseWriter, []byte) ([]map[string]interface{}, error) {
+	return func(_ http.ResponseWriter, body []byte) ([]map[string]interface{}, error) {
+		var logs []map[string]interface{}
+		if contentType == "application/json" {
+			err := json.Unmarshal(body, &logs)
+			return logs, err
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			v := make(map[string]interface{})
+			if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+				return nil, err
+			}
+			logs = append(logs, v)
+		}
+		return logs, nil
+	}
+}
+
+// values returns the values of key of the recorded logs, as strings
+func (rec *httpRecorder) values(key string) []string {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	values := make([]string, len(rec.logs))
+	for i, v := range rec.logs {
+		values[i], _ = v[key].(string)
+	}
+	return values
+}
+
+func (rec *httpRecorder) messages() []string {
+	return rec.values("msg")
+}
+
+// testBatchConfig creates a BatchConfig that only sends batches when they are
+// full or flushed, retrying without waiting long
+func testBatchConfig() BatchConfig {
+	config := DefaultBatchConfig()
+	config.Interval = 0
+	config.MinBackoff = time.Millisecond
+	return *config
+}
+
+func newHTTPConfig(url string) *HTTPConfig {
+	config := DefaultHTTPConfig()
+	config.URL = url
+	config.Batch = testBatchConfig()
+	return config
+}
+
+// testClientError checks that drinker drops a log the server behind rec
+// rejects with a client error, without sending it again
+func testClientError(t *testing.T, rec *httpRecorder, drinker interface {
+	Drinker
+	Flusher
+}) {
+	t.Helper()
+
+	drinker.Drink(map[string]interface{}{"msg": "invalid"})
+
+	if err := drinker.Flush(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(rec.requests) != 1 {
+		t.Fatalf("expected no retries, got %d requests", len(rec.requests))
+	}
+}
+
+func TestHTTPDrinker(t *testing.T) {
+	for _, encoding := range []HTTPEncoding{NDJSON, JSONArray} {
+		rec := new(httpRecorder)
+		srv := httptest.NewServer(rec)
+		defer srv.Close()
+
+		config := newHTTPConfig(srv.URL)
+		config.Encoding = encoding
+		config.Header = http.Header{"Authorization": {"Bearer token"}}
+		drinker, err := NewHTTPDrinker(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logger := newTestLager(t, drinker)
+
+		logger.Set("user", "marty").Infof("first")
+		logger.Warnf("second")
+
+		if err := drinker.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if msgs := rec.messages(); strings.Join(msgs, ",") != "first,second" {
+			t.Fatalf("expected first and second, got %v", msgs)
+		}
+
+		if len(rec.requests) != 1 {
+			t.Fatalf("expected 1 request, got %d", len(rec.requests))
+		}
+		if auth := rec.requests[0].Header.Get("Authorization"); auth != "Bearer token" {
+			t.Fatalf("expected the Authorization header, got %q", auth)
+		}
+		if rec.logs[0]["user"] != "marty" {
+			t.Fatalf("expected the context fields, got %v", rec.logs[0])
+		}
+	}
+}
+
+func TestHTTPDrinkerBatchSize(t *testing.T) {
+	rec := new(httpRecorder)
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	config := newHTTPConfig(srv.URL)
+	config.Batch.Size = 2
+	drinker, err := NewHTTPDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	for _, msg := range []string{"one", "two", "three"} {
+		drinker.Drink(map[string]interface{}{"msg": msg})
+	}
+
+	if err := drinker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(rec.requests))
+	}
+	if msgs := rec.messages(); strings.Join(msgs, ",") != "one,two,three" {
+		t.Fatalf("expected all messages, got %v", msgs)
+	}
+}
+
+func TestHTTPDrinkerRetries(t *testing.T) {
+	rec := &httpRecorder{fail: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	drinker, err := NewHTTPDrinker(newHTTPConfig(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	drinker.Drink(map[string]interface{}{"msg": "hello"})
+
+	if err := drinker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(rec.requests))
+	}
+	if msgs := rec.messages(); len(msgs) != 1 || msgs[0] != "hello" {
+		t.Fatalf("expected hello, got %v", msgs)
+	}
+}
+
+func TestHTTPDrinkerClientError(t *testing.T) {
+	rec := &httpRecorder{fail: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	drinker, err := NewHTTPDrinker(newHTTPConfig(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	testClientError(t, rec, drinker)
+}
+
+// blockingHandler blocks requests until release is closed
+func blockingHandler(next http.Handler, started chan<- struct{}, release <-chan struct{}) http.Handler {
+	var once sync.Once
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(started) })
+		<-release
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestHTTPDrinkerQueueFull(t *testing.T) {
+	rec := new(httpRecorder)
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(blockingHandler(rec, started, release))
+	defer srv.Close()
+
+	config := newHTTPConfig(srv.URL)
+	config.Batch.Size = 1
+	config.Batch.QueueSize = 1
+	drinker, err := NewHTTPDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	drinker.Drink(map[string]interface{}{"msg": "sending"})
+	<-started
+
+	if err := drinker.Drink(map[string]interface{}{"msg": "queued"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := drinker.Drink(map[string]interface{}{"msg": "dropped"}); err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	close(release)
+	drinker.Flush()
+
+	if msgs := rec.messages(); strings.Join(msgs, ",") != "sending,queued" {
+		t.Fatalf("expected sending and queued, got %v", msgs)
+	}
+}
+
+func TestHTTPDrinkerSpool(t *testing.T) {
+	rec := new(httpRecorder)
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(blockingHandler(rec, started, release))
+	defer srv.Close()
+
+	config := newHTTPConfig(srv.URL)
+	config.Batch.Size = 1
+	config.Batch.QueueSize = 1
+	config.Batch.SpoolDir = t.TempDir()
+	drinker, err := NewHTTPDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drinker.Drink(map[string]interface{}{"msg": "sending"})
+	<-started
+
+	for _, msg := range []string{"queued", "spooled"} {
+		if err := drinker.Drink(map[string]interface{}{"msg": msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(release)
+	if err := drinker.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if msgs := rec.messages(); strings.Join(msgs, ",") != "sending,queued,spooled" {
+		t.Fatalf("expected all messages, got %v", msgs)
+	}
+
+	if err := drinker.Drink(map[string]interface{}{"msg": "closed"}); err != ErrDrinkerClosed {
+		t.Fatalf("expected ErrDrinkerClosed, got %v", err)
+	}
+}
+
+func TestHTTPDrinkerInvalidURL(t *testing.T) {
+	if _, err := NewHTTPDrinker(newHTTPConfig("ftp://example.com")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
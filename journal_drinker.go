@@ -0,0 +1,310 @@
+//go:build linux
+
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// JournalConfig is the configuration for JournalDrinker
+type JournalConfig struct {
+	// Socket is the path of the journald native protocol socket
+	Socket string
+	// SyslogIdentifier identifies the program, the name of the executable if empty
+	SyslogIdentifier string
+}
+
+// DefaultJournalConfig creates a default JournalConfig
+func DefaultJournalConfig() *JournalConfig {
+	return &JournalConfig{
+		Socket: "/run/systemd/journal/socket",
+	}
+}
+
+// JournalDrinker is a Drinker that sends logs to systemd-journald using its
+// native protocol. The level is sent as PRIORITY, the message as MESSAGE, the
+// file as CODE_FILE and CODE_LINE, the function as CODE_FUNC, and context
+// fields under their keys upper-cased. Context fields that would take the name
+// of one of these are prefixed with FIELDS_. Entries too large for a datagram
+// are sent in a memfd.
+type JournalDrinker struct {
+	conn       *net.UnixConn
+	socket     *net.UnixAddr
+	identifier string
+
+	lock sync.Mutex
+}
+
+// NewJournalDrinker creates a new Journal Drinker and connects it to journald
+func NewJournalDrinker(config *JournalConfig) (*JournalDrinker, error) {
+	if config == nil {
+		config = DefaultJournalConfig()
+	}
+
+	if _, err := os.Stat(config.Socket); err != nil {
+		return nil, err
+	}
+
+	// the socket is not connected, as files can only be passed with WriteMsgUnix
+	// to an address
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	drkr := &JournalDrinker{
+		conn:       conn,
+		socket:     &net.UnixAddr{Name: config.Socket, Net: "unixgram"},
+		identifier: config.SyslogIdentifier,
+	}
+
+	if drkr.identifier == "" {
+		drkr.identifier = filepath.Base(os.Args[0])
+	}
+
+	return drkr, nil
+}
+
+// Drink drinks logs, using the default keys for the standard values
+func (drkr *JournalDrinker) Drink(v map[string]interface{}) error {
+	return drkr.DrinkEntry(entryFromMap(v))
+}
+
+// DrinkEntry drinks logs, reusing the cached encoding of the entry's fields
+func (drkr *JournalDrinker) DrinkEntry(e *Entry) error {
+	format := e.Format
+	if format == nil {
+		format = defaultFormat
+	}
+
+	b := new(bytes.Buffer)
+	appendJournalField(b, "MESSAGE", e.Message)
+
+	severity, ok := syslogSeverities[e.Level]
+	if !ok {
+		severity = syslogSeverities[Error]
+	}
+	appendJournalField(b, "PRIORITY", strconv.Itoa(severity))
+	appendJournalField(b, "SYSLOG_IDENTIFIER", drkr.identifier)
+
+	if e.File != "" {
+		file, line := e.File, ""
+		if i := strings.LastIndexByte(file, ':'); i >= 0 {
+			file, line = file[:i], file[i+1:]
+		}
+
+		appendJournalField(b, "CODE_FILE", file)
+		if line != "" {
+			appendJournalField(b, "CODE_LINE", line)
+		}
+	}
+
+	if e.Func != "" {
+		appendJournalField(b, "CODE_FUNC", e.Func)
+	}
+
+	if len(e.Stack) > 0 {
+		appendJournalField(b, journalName(format.Keys.Stacktrace), e.Stack.String())
+	}
+
+	b.Write(e.Fields.Encoded(drkr, drkr.encodeFields))
+
+	return drkr.send(b.Bytes())
+}
+
+// Close closes the connection to journald
+func (drkr *JournalDrinker) Close() error {
+	return drkr.conn.Close()
+}
+
+// encodeFields encodes fields as journal fields
+func (drkr *JournalDrinker) encodeFields(fields []Field) []byte {
+	b := new(bytes.Buffer)
+	for _, field := range fields {
+		if list, ok := field.Value.(ErrorList); ok {
+			for i, info := range list {
+				appendJournalField(b, journalFieldName(fmt.Sprintf("%s_%d", field.Key, i)), info.String())
+			}
+			continue
+		}
+
+		appendJournalField(b, journalFieldName(field.Key), fmt.Sprint(field.Value))
+	}
+	return b.Bytes()
+}
+
+// send sends an entry as a datagram, or in a sealed memfd if it is too large
+func (drkr *JournalDrinker) send(entry []byte) error {
+	drkr.lock.Lock()
+	defer drkr.lock.Unlock()
+
+	_, err := drkr.conn.WriteToUnix(entry, drkr.socket)
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+
+	f, err := journalFile(entry)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, _, err = drkr.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), drkr.socket)
+	return err
+}
+
+// appendJournalField appends a field, in the binary form if value has a newline
+func appendJournalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+
+	if strings.IndexByte(value, '\n') < 0 {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalName returns key as a journal field name: at most 64 upper case
+// letters, digits and '_', not starting with '_' or a digit
+func journalName(key string) string {
+	b := new(strings.Builder)
+	for _, r := range strings.ToUpper(key) {
+		if b.Len() == 64 {
+			break
+		}
+
+		switch {
+		case r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' || r == '_':
+			if b.Len() == 0 {
+				continue
+			}
+		default:
+			if b.Len() == 0 {
+				continue
+			}
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+
+	if b.Len() == 0 {
+		return "FIELD"
+	}
+	return b.String()
+}
+
+// journalStandardNames are the journal fields the drinker sends itself
+var journalStandardNames = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// journalFieldName returns the journal field name of a context field, prefixed
+// with "fields." if it would otherwise be one the drinker sends itself
+func journalFieldName(key string) string {
+	name := journalName(key)
+	if journalStandardNames[name] {
+		return journalName(fieldsPrefix + key)
+	}
+	return name
+}
+
+// memfdCreate is the memfd_create system call number of each architecture
+var memfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}
+
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+	fcntlAddSeals   = 1033
+	sealAll         = 0x1 | 0x2 | 0x4 | 0x8 // seal, shrink, grow and write
+)
+
+// journalFile returns a file holding entry to send to journald: a sealed
+// memfd, or an unlinked file in /dev/shm if memfds are not supported
+func journalFile(entry []byte) (*os.File, error) {
+	f, err := newMemfd()
+	if err != nil {
+		f, err = os.CreateTemp("/dev/shm", "lager-journal-")
+		if err != nil {
+			return nil, err
+		}
+		os.Remove(f.Name())
+	}
+
+	if _, err := f.Write(entry); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// journald only accepts memfds that are sealed
+	syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), fcntlAddSeals, sealAll)
+	return f, nil
+}
+
+// newMemfd creates a memfd that can be sealed
+func newMemfd() (*os.File, error) {
+	trap, ok := memfdCreate[runtime.GOARCH]
+	if !ok {
+		return nil, syscall.ENOSYS
+	}
+
+	name, err := syscall.BytePtrFromString("lager-journal")
+	if err != nil {
+		return nil, err
+	}
+
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	return os.NewFile(fd, "lager-journal"), nil
+}
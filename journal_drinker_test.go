@@ -0,0 +1,217 @@
+//go:build linux
+
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newJournalListener(t *testing.T) (*net.UnixConn, *JournalConfig) {
+	path := filepath.Join(t.TempDir(), "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	config := DefaultJournalConfig()
+	config.Socket = path
+	config.SyslogIdentifier = "lager"
+	return conn, config
+}
+
+// readJournal reads an entry sent to conn, from a datagram or a passed file
+func readJournal(t *testing.T, conn *net.UnixConn) map[string]string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 64*1024)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf[:n]
+	if oobn > 0 {
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fds, err := syscall.ParseUnixRights(&msgs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := os.NewFile(uintptr(fds[0]), "journal")
+		defer f.Close()
+
+		f.Seek(0, io.SeekStart)
+		if data, err = io.ReadAll(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return parseJournal(t, data)
+}
+
+// parseJournal parses the fields of an entry in the native protocol
+func parseJournal(t *testing.T, data []byte) map[string]string {
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			t.Fatalf("unterminated field %q", data)
+		}
+
+		line := string(data[:end])
+		data = data[end+1:]
+
+		if i := strings.IndexByte(line, '='); i >= 0 {
+			fields[line[:i]] = line[i+1:]
+			continue
+		}
+
+		size := binary.LittleEndian.Uint64(data)
+		fields[line] = string(data[8 : 8+size])
+		data = data[8+size+1:]
+	}
+	return fields
+}
+
+func TestJournalDrinker(t *testing.T) {
+	conn, config := newJournalListener(t)
+
+	drinker, err := NewJournalDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:   new(Levels).All(),
+		Drinker:  drinker,
+		FileType: ShortFile,
+		FuncName: true,
+	})
+
+	logger.Set("user.name", "marty\nmcfly").WithErrors(errors.New("late")).Warnf("hello")
+
+	fields := readJournal(t, conn)
+	expected := map[string]string{
+		"MESSAGE":           "hello",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "lager",
+		"CODE_FILE":         "journal_drinker_test.go",
+		"CODE_LINE":         "121",
+		"CODE_FUNC":         "github.com/doubledutch/lager.TestJournalDrinker",
+		"USER_NAME":         "marty\nmcfly",
+		"ERRORS_0":          "late",
+	}
+
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Fatalf("expected %s to be %q, got %q", key, value, fields[key])
+		}
+	}
+}
+
+func TestJournalDrinkerStandardNames(t *testing.T) {
+	conn, config := newJournalListener(t)
+
+	drinker, err := NewJournalDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).All(),
+		Drinker: drinker,
+	})
+
+	logger.Set("priority", "0").Set("Message", "forged").Set("code.line", "1").Errorf("hello")
+
+	fields := readJournal(t, conn)
+	expected := map[string]string{
+		"MESSAGE":          "hello",
+		"PRIORITY":         "3",
+		"FIELDS_PRIORITY":  "0",
+		"FIELDS_MESSAGE":   "forged",
+		"FIELDS_CODE_LINE": "1",
+	}
+
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Fatalf("expected %s to be %q, got %q", key, value, fields[key])
+		}
+	}
+
+	if _, ok := fields["CODE_LINE"]; ok {
+		t.Fatalf("expected no CODE_LINE without a file, got %v", fields)
+	}
+}
+
+func TestJournalDrinkerLargeEntry(t *testing.T) {
+	conn, config := newJournalListener(t)
+
+	drinker, err := NewJournalDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drinker.Close()
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).All(),
+		Drinker: drinker,
+	})
+
+	large := strings.Repeat("x", 4*1024*1024)
+	logger.Set("large", large).Errorf("large")
+
+	fields := readJournal(t, conn)
+	if fields["MESSAGE"] != "large" || fields["LARGE"] != large {
+		t.Fatalf("expected large entry, got %d fields", len(fields))
+	}
+}
+
+func TestJournalName(t *testing.T) {
+	names := map[string]string{
+		"user":                  "USER",
+		"user.id":               "USER_ID",
+		"_private":              "PRIVATE",
+		"2fa":                   "FA",
+		"é":                     "FIELD",
+		"":                      "FIELD",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+	}
+
+	for key, expected := range names {
+		if actual := journalName(key); actual != expected {
+			t.Fatalf("expected '%s' for '%s', got '%s'", expected, key, actual)
+		}
+	}
+}
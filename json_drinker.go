@@ -14,6 +14,7 @@ limitations under the License.
 package lager
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -33,11 +34,93 @@ func NewJSONDrinker(output io.Writer) Drinker {
 
 // Drink drinks logs
 func (drkr *JSONDrinker) Drink(v map[string]interface{}) error {
-	data, err := json.Marshal(v)
-	if err != nil {
+	b := new(bytes.Buffer)
+	b.WriteByte('{')
+
+	for i, key := range orderedKeys(v) {
+		data, err := jsonMember(key, v[key])
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(data)
+	}
+
+	b.WriteString("}\n")
+
+	_, err := drkr.output.Write(b.Bytes())
+	return err
+}
+
+// DrinkEntry drinks logs, reusing the cached JSON encoding of the entry's fields
+func (drkr *JSONDrinker) DrinkEntry(e *Entry) error {
+	b := new(bytes.Buffer)
+	if err := appendJSONEntry(b, e, drkr); err != nil {
 		return err
 	}
+	b.WriteByte('\n')
+
+	_, err := drkr.output.Write(b.Bytes())
+	return err
+}
+
+// appendJSONEntry appends e as a JSON object, caching the encoding of its
+// fields under key
+func appendJSONEntry(b *bytes.Buffer, e *Entry, key interface{}) error {
+	b.WriteByte('{')
+
+	for i, field := range e.Standard() {
+		data, err := jsonMember(field.Key, field.Value)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(data)
+	}
 
-	fmt.Fprintln(drkr.output, string(data))
+	b.Write(e.Fields.Encoded(key, encodeJSONFields))
+	b.WriteByte('}')
 	return nil
 }
+
+// encodeJSONFields encodes fields as JSON members, each preceded by a comma.
+// Values that cannot be marshaled are logged as their string form.
+func encodeJSONFields(fields []Field) []byte {
+	b := new(bytes.Buffer)
+
+	for _, field := range fields {
+		data, err := jsonMember(field.Key, field.Value)
+		if err != nil {
+			data, _ = jsonMember(field.Key, fmt.Sprint(field.Value))
+		}
+
+		b.WriteByte(',')
+		b.Write(data)
+	}
+
+	return b.Bytes()
+}
+
+// jsonMember encodes key and value as a JSON object member
+func jsonMember(key string, value interface{}) ([]byte, error) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, len(k)+len(v)+1)
+	data = append(data, k...)
+	data = append(data, ':')
+	return append(data, v...), nil
+}
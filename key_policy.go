@@ -0,0 +1,66 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import "fmt"
+
+// KeyPolicy represents how context fields with reserved keys, the keys used
+// for the standard values a lager logs, are handled. The file, func and
+// stacktrace keys are only reserved by lagers that log those values. The
+// error keys of WithError and WithErrors are context fields like any other.
+type KeyPolicy uint8
+
+const (
+	// OverwriteReserved logs the standard values in place of context fields
+	// with reserved keys
+	OverwriteReserved KeyPolicy = iota
+	// RenameReserved logs context fields with reserved keys prefixed with "fields."
+	RenameReserved
+	// PrefixFields logs all context fields prefixed with "fields."
+	PrefixFields
+	// RejectReserved drops context fields with reserved keys and reports
+	// a ReservedKeyError
+	RejectReserved
+)
+
+// fieldsPrefix is the prefix used by RenameReserved and PrefixFields
+const fieldsPrefix = "fields."
+
+// ReservedKeyError is reported when RejectReserved drops a context field
+type ReservedKeyError struct {
+	Key string
+}
+
+func (err *ReservedKeyError) Error() string {
+	return fmt.Sprintf("lager: %q is a reserved key", err.Key)
+}
+
+// key returns the key a context field set with key is logged under, given
+// whether a key is reserved
+func (kp KeyPolicy) key(reserved func(string) bool, key string) (string, error) {
+	switch kp {
+	case RenameReserved:
+		if reserved(key) {
+			return fieldsPrefix + key, nil
+		}
+	case PrefixFields:
+		return fieldsPrefix + key, nil
+	case RejectReserved:
+		if reserved(key) {
+			return "", &ReservedKeyError{Key: key}
+		}
+	}
+
+	return key, nil
+}
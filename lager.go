@@ -21,6 +21,7 @@ type Lager interface {
 	Infof(msg string, v ...interface{})
 	Warnf(msg string, v ...interface{})
 	Errorf(msg string, v ...interface{})
+	Enabled(level Level) bool
 	SetLevels(levels *Levels)
 	Levels() *Levels
 }
@@ -71,17 +72,18 @@ func (lgr *lager) Errorf(msg string, v ...interface{}) {
 }
 
 func (lgr *lager) logf(lvl Level, msg string, v ...interface{}) {
-	if lgr.levels == nil {
-		return
-	}
-
-	if !lgr.levels.Contains(lvl) {
+	if !lgr.Enabled(lvl) {
 		return
 	}
 
 	lgr.pale.Logf(lvl, msg, v...)
 }
 
+// Enabled returns whether logs with level are written
+func (lgr *lager) Enabled(level Level) bool {
+	return lgr.levels != nil && lgr.levels.Contains(level)
+}
+
 func (lgr *lager) SetLevels(levels *Levels) {
 	lgr.levels.Replace(levels)
 }
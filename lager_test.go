@@ -105,3 +105,22 @@ func TestSetLevels(t *testing.T) {
 	lgr := NewLogLager(nil)
 	lgr.SetLevels(LevelsFromString("IE"))
 }
+
+func TestLagerEnabled(t *testing.T) {
+	lgr := NewLogLager(&LogConfig{
+		Levels: LevelsFromString("WE"),
+		Output: ioutil.Discard,
+	})
+
+	for _, lvl := range []Level{Warn, Error} {
+		if !lgr.Enabled(lvl) {
+			t.Fatalf("expected %s to be enabled", lvl)
+		}
+	}
+
+	for _, lvl := range []Level{Trace, Debug, Info} {
+		if lgr.Enabled(lvl) {
+			t.Fatalf("expected %s to be disabled", lvl)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lagertest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a lager.Clock that only changes time when it is set or advanced
+type Clock struct {
+	now  time.Time
+	lock sync.RWMutex
+}
+
+// NewClock creates a Clock set to now
+func NewClock(now time.Time) *Clock {
+	return &Clock{
+		now: now,
+	}
+}
+
+// Now returns the time the clock is set to
+func (c *Clock) Now() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.now
+}
+
+// Set sets the clock to now
+func (c *Clock) Set(now time.Time) {
+	c.lock.Lock()
+	c.now = now
+	c.lock.Unlock()
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	c.lock.Unlock()
+}
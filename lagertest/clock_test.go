@@ -0,0 +1,62 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lagertest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/doubledutch/lager"
+)
+
+func TestClock(t *testing.T) {
+	now := time.Date(2015, 10, 21, 16, 29, 0, 0, time.UTC)
+
+	clock := NewClock(now)
+	if !clock.Now().Equal(now) {
+		t.Fatalf("expected %s, got %s", now, clock.Now())
+	}
+
+	clock.Advance(time.Minute)
+	if expected := now.Add(time.Minute); !clock.Now().Equal(expected) {
+		t.Fatalf("expected %s, got %s", expected, clock.Now())
+	}
+
+	clock.Set(now)
+	if !clock.Now().Equal(now) {
+		t.Fatalf("expected %s, got %s", now, clock.Now())
+	}
+}
+
+func TestClockContextLager(t *testing.T) {
+	buf := new(bytes.Buffer)
+	clock := NewClock(time.Date(2015, 10, 21, 16, 29, 0, 0, time.UTC))
+
+	lgr := lager.NewContextLager(&lager.ContextConfig{
+		Levels:  new(lager.Levels).Set(lager.Info),
+		Drinker: lager.NewJSONDrinker(buf),
+		Clock:   clock,
+	})
+
+	child := lgr.Child()
+	child.Set("a", "one")
+	clock.Advance(time.Second)
+	child.Infof("hello")
+
+	expected := `{"time":"2015-10-21T16:29:01Z","level":"Info","msg":"hello","a":"one"}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, buf.String())
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lagertest provides helpers for testing code that logs with lager.
+package lagertest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doubledutch/lager"
+)
+
+// keys are the default keys of the standard values, used for logs drunk as maps
+var keys = lager.DefaultFormat().Keys
+
+// Entry is a log recorded by Drinker
+type Entry struct {
+	Time       time.Time
+	Level      lager.Level
+	Message    string
+	File       string
+	Func       string
+	Stacktrace string
+	Fields     map[string]interface{}
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("%s %q %v", e.Level, e.Message, e.Fields)
+}
+
+// Drinker is a lager.Drinker that records logs in memory. It is safe to use
+// from multiple goroutines.
+type Drinker struct {
+	entries []Entry
+	lock    sync.Mutex
+}
+
+// NewDrinker creates a new Drinker
+func NewDrinker() *Drinker {
+	return new(Drinker)
+}
+
+// Drink records a log from its map, using the default keys for standard values
+func (drkr *Drinker) Drink(v map[string]interface{}) error {
+	e := Entry{
+		Fields: make(map[string]interface{}, len(v)),
+	}
+
+	for key, value := range v {
+		str, _ := value.(string)
+
+		switch key {
+		case keys.Time:
+			e.Time, _ = time.Parse(time.RFC3339, str)
+		case keys.Level:
+			e.Level = lager.ParseLevel(str)
+		case keys.Message:
+			e.Message = str
+		case keys.File:
+			e.File = str
+		case keys.Func:
+			e.Func = str
+		case keys.Stacktrace:
+			e.Stacktrace = fmt.Sprint(value)
+		default:
+			e.Fields[key] = value
+		}
+	}
+
+	drkr.record(e)
+	return nil
+}
+
+// DrinkEntry records a log
+func (drkr *Drinker) DrinkEntry(e *lager.Entry) error {
+	fields := make(map[string]interface{}, e.Fields.Len())
+	for _, field := range e.Fields.List() {
+		fields[field.Key] = field.Value
+	}
+
+	drkr.record(Entry{
+		Time:       e.Time,
+		Level:      e.Level,
+		Message:    e.Message,
+		File:       e.File,
+		Func:       e.Func,
+		Stacktrace: e.Stack.String(),
+		Fields:     fields,
+	})
+	return nil
+}
+
+func (drkr *Drinker) record(e Entry) {
+	drkr.lock.Lock()
+	drkr.entries = append(drkr.entries, e)
+	drkr.lock.Unlock()
+}
+
+// Entries returns all recorded logs in the order they were logged
+func (drkr *Drinker) Entries() []Entry {
+	drkr.lock.Lock()
+	defer drkr.lock.Unlock()
+
+	entries := make([]Entry, len(drkr.entries))
+	copy(entries, drkr.entries)
+	return entries
+}
+
+// Filter returns the recorded logs with any of the levels in lvls,
+// such as lager.Warn|lager.Error
+func (drkr *Drinker) Filter(lvls lager.Level) []Entry {
+	var entries []Entry
+	for _, e := range drkr.Entries() {
+		if e.Level&lvls != 0 {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Reset forgets all recorded logs
+func (drkr *Drinker) Reset() {
+	drkr.lock.Lock()
+	drkr.entries = nil
+	drkr.lock.Unlock()
+}
+
+// Logged returns whether a log was recorded at level with a message
+// containing msg and all of fields.
+func (drkr *Drinker) Logged(level lager.Level, msg string, fields map[string]interface{}) bool {
+	for _, e := range drkr.Filter(level) {
+		if strings.Contains(e.Message, msg) && hasFields(e, fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertLogged fails t unless a log was recorded at level with a message
+// containing msg and all of fields.
+func (drkr *Drinker) AssertLogged(t testing.TB, level lager.Level, msg string, fields map[string]interface{}) {
+	t.Helper()
+
+	if drkr.Logged(level, msg, fields) {
+		return
+	}
+
+	t.Errorf("expected %s log containing %q with fields %v, got:", level, msg, fields)
+	for _, e := range drkr.Entries() {
+		t.Errorf("\t%s", e)
+	}
+}
+
+func hasFields(e Entry, fields map[string]interface{}) bool {
+	for key, expected := range fields {
+		actual, ok := e.Fields[key]
+		if !ok || !reflect.DeepEqual(actual, expected) {
+			return false
+		}
+	}
+	return true
+}
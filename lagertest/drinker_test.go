@@ -0,0 +1,90 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lagertest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/doubledutch/lager"
+)
+
+func TestDrinker(t *testing.T) {
+	drinker := NewDrinker()
+
+	lgr := lager.NewContextLager(&lager.ContextConfig{
+		Levels:  new(lager.Levels).All(),
+		Drinker: drinker,
+	})
+
+	lgr.Set("app", "test")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lgr.Infof("hello %d", i)
+		}(i)
+	}
+	wg.Wait()
+
+	lgr.With(map[string]string{"user": "marty"}).Warnf("great scott")
+
+	if n := len(drinker.Entries()); n != 11 {
+		t.Fatalf("expected 11 entries, got %d", n)
+	}
+
+	if n := len(drinker.Filter(lager.Warn | lager.Error)); n != 1 {
+		t.Fatalf("expected 1 warning, got %d", n)
+	}
+
+	drinker.AssertLogged(t, lager.Info, "hello 7", map[string]interface{}{"app": "test"})
+	drinker.AssertLogged(t, lager.Warn, "scott", map[string]interface{}{"user": "marty", "app": "test"})
+
+	if drinker.Logged(lager.Error, "scott", nil) {
+		t.Fatal("unexpected error log")
+	}
+
+	if drinker.Logged(lager.Warn, "scott", map[string]interface{}{"user": "doc"}) {
+		t.Fatal("unexpected log with user doc")
+	}
+
+	drinker.Reset()
+	if n := len(drinker.Entries()); n != 0 {
+		t.Fatalf("expected no entries after reset, got %d", n)
+	}
+}
+
+func TestDrinkerDrink(t *testing.T) {
+	drinker := NewDrinker()
+
+	drinker.Drink(map[string]interface{}{
+		"time":  "2015-10-21T16:29:00Z",
+		"level": "Error",
+		"msg":   "hello",
+		"a":     "one",
+	})
+
+	entries := drinker.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	if entries[0].Time.IsZero() {
+		t.Fatal("expected time to be parsed")
+	}
+
+	drinker.AssertLogged(t, lager.Error, "hello", map[string]interface{}{"a": "one"})
+}
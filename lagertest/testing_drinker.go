@@ -0,0 +1,94 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lagertest
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/doubledutch/lager"
+)
+
+// TestingDrinker is a lager.Drinker that writes logs to testing.TB, so they
+// are attached to the test that owns them and only shown when it fails or with
+// -v. Logs with a file, as logged by lagers with a FileType, are attributed to
+// it rather than to lager's code. From Go 1.25 they are written to the test's
+// output in place of t.Log's location. Logs drunk after the test completes are
+// discarded.
+type TestingDrinker struct {
+	t        testing.TB
+	buf      bytes.Buffer
+	drinker  lager.EntryDrinker
+	finished bool
+	lock     sync.Mutex
+}
+
+// NewTestingDrinker creates a TestingDrinker that logs to t in the format of
+// lager.LogDrinker
+func NewTestingDrinker(t testing.TB) *TestingDrinker {
+	drkr := &TestingDrinker{t: t}
+	drkr.drinker = lager.NewLogDrinker(&drkr.buf).(lager.EntryDrinker)
+	t.Cleanup(drkr.done)
+	return drkr
+}
+
+// NewContextLager creates a ContextLager for t that logs all levels, along with
+// the file and line number of each log, to a TestingDrinker
+func NewContextLager(t testing.TB) lager.ContextLager {
+	return lager.NewContextLager(&lager.ContextConfig{
+		Levels:   new(lager.Levels).All(),
+		Drinker:  NewTestingDrinker(t),
+		FileType: lager.ShortFile,
+	})
+}
+
+// Drink drinks logs
+func (drkr *TestingDrinker) Drink(v map[string]interface{}) error {
+	file, _ := v[lager.DefaultFormat().Keys.File].(string)
+	return drkr.write(file, func() error { return drkr.drinker.Drink(v) })
+}
+
+// DrinkEntry drinks logs
+func (drkr *TestingDrinker) DrinkEntry(e *lager.Entry) error {
+	return drkr.write(e.File, func() error { return drkr.drinker.DrinkEntry(e) })
+}
+
+// write formats a log with drink and writes it to the test, prefixed with
+// file, the location of the call that logged
+func (drkr *TestingDrinker) write(file string, drink func() error) error {
+	drkr.lock.Lock()
+	defer drkr.lock.Unlock()
+
+	if drkr.finished {
+		return nil
+	}
+
+	drkr.buf.Reset()
+	if err := drink(); err != nil {
+		return err
+	}
+
+	msg := bytes.TrimRight(drkr.buf.Bytes(), " \n")
+	if file != "" {
+		msg = append([]byte(file+": "), msg...)
+	}
+	return drkr.output(msg)
+}
+
+func (drkr *TestingDrinker) done() {
+	drkr.lock.Lock()
+	drkr.finished = true
+	drkr.lock.Unlock()
+}
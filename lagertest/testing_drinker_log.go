@@ -0,0 +1,23 @@
+//go:build !go1.25
+
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lagertest
+
+// output logs msg with t.Log, which adds the location of the TestingDrinker,
+// as testing.TB has no Output before Go 1.25
+func (drkr *TestingDrinker) output(msg []byte) error {
+	drkr.t.Log(string(msg))
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build go1.25
+
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lagertest
+
+// output writes msg to the test's output, without the location t.Log adds
+func (drkr *TestingDrinker) output(msg []byte) error {
+	_, err := drkr.t.Output().Write(append(msg, '\n'))
+	return err
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lagertest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/doubledutch/lager"
+)
+
+// writerFunc is an io.Writer that calls itself
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// recordingT records what is logged to it
+type recordingT struct {
+	testing.TB
+	logs     []string
+	cleanups []func()
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Log(args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprint(args...))
+}
+
+func (t *recordingT) Output() io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		t.Log(string(p))
+		return len(p), nil
+	})
+}
+
+func (t *recordingT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func TestTestingDrinker(t *testing.T) {
+	rt := new(recordingT)
+
+	lgr := NewContextLager(rt)
+	lgr.Set("a", "one")
+	lgr.Debugf("hello")
+
+	for _, cleanup := range rt.cleanups {
+		cleanup()
+	}
+	lgr.Debugf("too late")
+
+	if len(rt.logs) != 1 {
+		t.Fatalf("expected 1 log, got %d: %v", len(rt.logs), rt.logs)
+	}
+
+	log := rt.logs[0]
+	if !strings.HasPrefix(log, "testing_drinker_test.go:") {
+		t.Fatalf("expected '%s' to be attributed to the test", log)
+	}
+	if strings.HasSuffix(strings.TrimSuffix(log, "\n"), " ") {
+		t.Fatalf("expected no trailing space in '%s'", log)
+	}
+
+	for _, expected := range []string{"level=Debug", "msg=hello", "file=\"testing_drinker_test.go:", "a=one"} {
+		if !strings.Contains(log, expected) {
+			t.Fatalf("expected '%s' to contain '%s'", log, expected)
+		}
+	}
+}
+
+func TestTestingDrinkerParallel(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		i := i
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			NewContextLager(t).Infof("subtest %d", i)
+		})
+	}
+}
+
+// line returns the line it is called from
+func line() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+// logAt logs with lgr, a lager that skips logAt's frame, that it logged at line
+func logAt(lgr lager.ContextLager, line int) {
+	lgr.Infof("logged at %d", line)
+}
+
+func TestTestingDrinkerAttribution(t *testing.T) {
+	if os.Getenv("LAGERTEST_ATTRIBUTION") != "" {
+		lgr := NewContextLager(t)
+		lgr.Infof("logged at %d", line())
+		logAt(lgr.AddCallerSkip(1), line())
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTestingDrinkerAttribution$", "-test.v")
+	cmd.Env = append(os.Environ(), "LAGERTEST_ATTRIBUTION=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+
+	re := regexp.MustCompile(`testing_drinker_test.go:(\d+): .*msg="logged at (\d+)" file="testing_drinker_test.go:(\d+)"$`)
+	logs := 0
+	for _, l := range bytes.Split(out, []byte("\n")) {
+		m := re.FindSubmatch(l)
+		if m == nil {
+			continue
+		}
+
+		logs++
+		if string(m[1]) != string(m[2]) || string(m[3]) != string(m[2]) {
+			t.Fatalf("expected the log to be attributed to line %s, got %s and file %s", m[2], m[1], m[3])
+		}
+	}
+
+	if logs != 2 {
+		t.Fatalf("expected 2 logs attributed to the test, got:\n%s", out)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+// Valuer is a value that is only evaluated when a log is written, so it costs
+// nothing for levels that are not logged. Valuers are accepted as context
+// values by SetLazy and as arguments to the level methods.
+type Valuer interface {
+	Value() interface{}
+}
+
+// Lazy is a Valuer that calls the function
+type Lazy func() interface{}
+
+// Value calls the function
+func (fn Lazy) Value() interface{} {
+	return fn()
+}
+
+// resolveArgs returns v with Valuers evaluated
+func resolveArgs(v []interface{}) []interface{} {
+	var resolved []interface{}
+	for i, arg := range v {
+		valuer, ok := arg.(Valuer)
+		if !ok {
+			continue
+		}
+
+		if resolved == nil {
+			resolved = make([]interface{}, len(v))
+			copy(resolved, v)
+		}
+		resolved[i] = valuer.Value()
+	}
+
+	if resolved == nil {
+		return v
+	}
+	return resolved
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Info),
+		Drinker: NewJSONDrinker(buf),
+	})
+
+	fieldCalls, argCalls := 0, 0
+	logger.SetLazy("dump", Lazy(func() interface{} {
+		fieldCalls++
+		return map[string]int{"calls": fieldCalls}
+	}))
+
+	arg := Lazy(func() interface{} {
+		argCalls++
+		return "expensive"
+	})
+
+	logger.Debugf("not logged %v", arg)
+	if fieldCalls != 0 || argCalls != 0 {
+		t.Fatalf("expected no evaluation for disabled levels, got %d and %d", fieldCalls, argCalls)
+	}
+
+	logger.Infof("logged %v", arg)
+	logger.Child().Infof("logged again")
+	if fieldCalls != 2 || argCalls != 1 {
+		t.Fatalf("expected evaluation for each log, got %d and %d", fieldCalls, argCalls)
+	}
+
+	dec := json.NewDecoder(buf)
+	for i := 1; i <= 2; i++ {
+		var actual struct {
+			Msg  string         `json:"msg"`
+			Dump map[string]int `json:"dump"`
+		}
+		if err := dec.Decode(&actual); err != nil {
+			t.Fatal(err)
+		}
+
+		if actual.Dump["calls"] != i {
+			t.Fatalf("expected dump calls %d, got %v", i, actual.Dump)
+		}
+
+		if i == 1 && actual.Msg != "logged expensive" {
+			t.Fatalf("expected logged expensive, got %s", actual.Msg)
+		}
+	}
+}
+
+func TestFieldsResolved(t *testing.T) {
+	fields := newFields(map[string]string{"a": "one"})
+	if fields.Resolved() != fields {
+		t.Fatal("expected fields without Valuers to be resolved as is")
+	}
+
+	lazy := fields.with(Field{Key: "b", Value: Lazy(func() interface{} { return 2 })})
+	resolved := lazy.Resolved()
+	if value, _ := resolved.Get("b"); value != 2 {
+		t.Fatalf("expected b == 2, got %v", value)
+	}
+}
+
+func TestLazyLogLager(t *testing.T) {
+	for _, raw := range []bool{false, true} {
+		buf := new(bytes.Buffer)
+
+		logger := NewLogLager(&LogConfig{
+			Levels:            new(Levels).Set(Info),
+			Output:            buf,
+			AllowControlChars: raw,
+		})
+
+		calls := 0
+		arg := Lazy(func() interface{} {
+			calls++
+			return "expensive"
+		})
+
+		logger.Debugf("not logged %v", arg)
+		logger.Infof("lazy=%v", arg)
+
+		if calls != 1 {
+			t.Fatalf("expected 1 evaluation, got %d", calls)
+		}
+		if !strings.HasSuffix(buf.String(), "lazy=expensive\n") {
+			t.Fatalf("expected lazy=expensive, got %q", buf.String())
+		}
+	}
+}
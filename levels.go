@@ -13,7 +13,10 @@ limitations under the License.
 
 package lager
 
-import "sync"
+import (
+	"strings"
+	"sync"
+)
 
 // Level represents a logging level
 type Level uint
@@ -61,6 +64,17 @@ func LevelsFromString(sLevels string) *Levels {
 	return levels
 }
 
+// ParseLevel returns the level named str, such as "info", ignoring case, or 0
+// if there is none
+func ParseLevel(str string) Level {
+	for _, lvl := range []Level{Trace, Debug, Info, Warn, Error} {
+		if strings.EqualFold(lvl.String(), str) {
+			return lvl
+		}
+	}
+	return 0
+}
+
 // Set sets a log level
 func (lvls *Levels) Set(level Level) *Levels {
 	lvls.lock.Lock()
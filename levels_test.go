@@ -98,3 +98,21 @@ func TestLevelsUnset(t *testing.T) {
 		t.Fatal("expected levels to not contain debug")
 	}
 }
+
+func TestParseLevel(t *testing.T) {
+	names := map[string]Level{
+		"Trace": Trace,
+		"debug": Debug,
+		"INFO":  Info,
+		"warn":  Warn,
+		"Error": Error,
+		"fatal": 0,
+		"":      0,
+	}
+
+	for name, expected := range names {
+		if actual := ParseLevel(name); actual != expected {
+			t.Fatalf("expected %s for '%s', got %s", expected, name, actual)
+		}
+	}
+}
@@ -14,6 +14,7 @@ limitations under the License.
 package lager
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -23,6 +24,10 @@ import (
 type LogConfig struct {
 	Levels *Levels
 	Output io.Writer
+
+	// AllowControlChars logs control characters in messages as is, instead of
+	// escaping them. Only use it for trusted input.
+	AllowControlChars bool
 }
 
 // DefaultLogConfig is the default config
@@ -37,6 +42,7 @@ func DefaultLogConfig() *LogConfig {
 type LogLager struct {
 	Lager
 	logger *log.Logger
+	raw    bool
 }
 
 // NewLogLager creates a new LogLager
@@ -47,6 +53,7 @@ func NewLogLager(config *LogConfig) Lager {
 
 	logger := &LogLager{
 		logger: log.New(config.Output, "", log.LstdFlags),
+		raw:    config.AllowControlChars,
 	}
 
 	logger.Lager = newLager(logger, config.Levels)
@@ -57,5 +64,11 @@ func NewLogLager(config *LogConfig) Lager {
 // Logf will log the given msg formatted with v if min is greater than or equal
 // to the log level of LogLager
 func (lgr *LogLager) Logf(level Level, msg string, v ...interface{}) {
-	lgr.logger.Printf(msg, v...)
+	s := fmt.Sprintf(msg, resolveArgs(v)...)
+	if lgr.raw {
+		lgr.logger.Print(s)
+		return
+	}
+
+	lgr.logger.Print(escapeControl(s))
 }
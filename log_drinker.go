@@ -22,26 +22,32 @@ import (
 // LogDrinker is a Drinker that uses log.Logger
 type LogDrinker struct {
 	output io.Writer
+	raw    bool
 }
 
-// NewLogDrinker creates a new Log Drinker
+// NewLogDrinker creates a new Log Drinker. Control characters in keys and
+// values are escaped, so input cannot forge log lines.
 func NewLogDrinker(output io.Writer) Drinker {
 	return &LogDrinker{
 		output: output,
 	}
 }
 
+// NewRawLogDrinker creates a new Log Drinker that writes keys and unquoted
+// values as is, including control characters. Only use it for trusted input.
+func NewRawLogDrinker(output io.Writer) Drinker {
+	return &LogDrinker{
+		output: output,
+		raw:    true,
+	}
+}
+
 // Drink drinks logs
 func (drkr *LogDrinker) Drink(v map[string]interface{}) error {
 	b := new(bytes.Buffer)
 
-	for _, key := range []string{"time", "level", "msg"} {
-		appendKeyValue(b, key, v[key])
-		delete(v, key)
-	}
-
-	for key, value := range v {
-		appendKeyValue(b, key, value)
+	for _, key := range orderedKeys(v) {
+		drkr.appendKeyValue(b, key, v[key])
 	}
 
 	b.WriteByte('\n')
@@ -50,6 +56,32 @@ func (drkr *LogDrinker) Drink(v map[string]interface{}) error {
 	return nil
 }
 
+// DrinkEntry drinks logs, reusing the cached encoding of the entry's fields
+func (drkr *LogDrinker) DrinkEntry(e *Entry) error {
+	b := new(bytes.Buffer)
+
+	for _, field := range e.Standard() {
+		drkr.appendKeyValue(b, field.Key, field.Value)
+	}
+
+	b.Write(e.Fields.Encoded(drkr, drkr.encodeFields))
+	b.WriteByte('\n')
+
+	_, err := drkr.output.Write(b.Bytes())
+	return err
+}
+
+// encodeFields encodes fields as key=value pairs
+func (drkr *LogDrinker) encodeFields(fields []Field) []byte {
+	b := new(bytes.Buffer)
+
+	for _, field := range fields {
+		drkr.appendKeyValue(b, field.Key, field.Value)
+	}
+
+	return b.Bytes()
+}
+
 func needsQuoting(text string) bool {
 	for _, ch := range text {
 		if !((ch >= 'a' && ch <= 'z') ||
@@ -62,7 +94,18 @@ func needsQuoting(text string) bool {
 	return true
 }
 
-func appendKeyValue(b *bytes.Buffer, key string, value interface{}) {
+func (drkr *LogDrinker) appendKeyValue(b *bytes.Buffer, key string, value interface{}) {
+	if list, ok := value.(ErrorList); ok {
+		for i, info := range list {
+			drkr.appendKeyValue(b, fmt.Sprintf("%s.%d", key, i), info)
+		}
+		return
+	}
+
+	if !drkr.raw {
+		key = escapeControl(key)
+	}
+
 	b.WriteString(key)
 	b.WriteByte('=')
 
@@ -80,8 +123,19 @@ func appendKeyValue(b *bytes.Buffer, key string, value interface{}) {
 		} else {
 			fmt.Fprintf(b, "%q", value)
 		}
+	case fmt.Stringer:
+		str := value.String()
+		if needsQuoting(str) {
+			b.WriteString(str)
+		} else {
+			fmt.Fprintf(b, "%q", str)
+		}
 	default:
-		fmt.Fprint(b, value)
+		str := fmt.Sprint(value)
+		if !drkr.raw {
+			str = escapeControl(str)
+		}
+		b.WriteString(str)
 	}
 
 	b.WriteByte(' ')
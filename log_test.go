@@ -70,3 +70,29 @@ func TestDefaultLogConfig(t *testing.T) {
 func TestDefaultLog(t *testing.T) {
 	NewLogLager(nil)
 }
+
+func TestLogEscapesControlChars(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewLogLager(&LogConfig{
+		Levels: new(Levels).Set(Error),
+		Output: buf,
+	})
+
+	logger.Errorf("ok\n%s", "forged")
+	if strings.Count(buf.String(), "\n") != 1 || !strings.HasSuffix(buf.String(), `ok\nforged`+"\n") {
+		t.Fatalf("expected a single escaped line, got %q", buf.String())
+	}
+
+	buf.Reset()
+	logger = NewLogLager(&LogConfig{
+		Levels:            new(Levels).Set(Error),
+		Output:            buf,
+		AllowControlChars: true,
+	})
+
+	logger.Errorf("ok\n%s", "trusted")
+	if strings.Count(buf.String(), "\n") != 2 {
+		t.Fatalf("expected control characters as is, got %q", buf.String())
+	}
+}
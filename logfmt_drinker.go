@@ -0,0 +1,261 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// LogfmtDrinker is a Drinker that logs in the logfmt format
+type LogfmtDrinker struct {
+	output io.Writer
+}
+
+// NewLogfmtDrinker creates a new logfmt Drinker
+func NewLogfmtDrinker(output io.Writer) Drinker {
+	return &LogfmtDrinker{
+		output: output,
+	}
+}
+
+// Drink drinks logs
+func (drkr *LogfmtDrinker) Drink(v map[string]interface{}) error {
+	b := new(bytes.Buffer)
+	for i, key := range orderedKeys(v) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		appendLogfmt(b, key, v[key])
+	}
+	b.WriteByte('\n')
+
+	_, err := drkr.output.Write(b.Bytes())
+	return err
+}
+
+// DrinkEntry drinks logs, reusing the cached encoding of the entry's fields
+func (drkr *LogfmtDrinker) DrinkEntry(e *Entry) error {
+	b := new(bytes.Buffer)
+	for i, field := range e.Standard() {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		appendLogfmt(b, field.Key, field.Value)
+	}
+
+	b.Write(e.Fields.Encoded(drkr, drkr.encodeFields))
+	b.WriteByte('\n')
+
+	_, err := drkr.output.Write(b.Bytes())
+	return err
+}
+
+// encodeFields encodes fields as logfmt pairs, each preceded by a space
+func (drkr *LogfmtDrinker) encodeFields(fields []Field) []byte {
+	b := new(bytes.Buffer)
+	for _, field := range fields {
+		b.WriteByte(' ')
+		appendLogfmt(b, field.Key, field.Value)
+	}
+	return b.Bytes()
+}
+
+// appendLogfmt appends key=value, sanitizing the key and quoting the value as needed
+func appendLogfmt(b *bytes.Buffer, key string, value interface{}) {
+	if list, ok := value.(ErrorList); ok {
+		for i, info := range list {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			appendLogfmt(b, fmt.Sprintf("%s.%d", key, i), info)
+		}
+		return
+	}
+
+	appendLogfmtKey(b, key)
+	b.WriteByte('=')
+
+	var str string
+	switch value := value.(type) {
+	case nil:
+		return
+	case string:
+		str = value
+	case error:
+		str = value.Error()
+	case fmt.Stringer:
+		str = value.String()
+	default:
+		str = fmt.Sprint(value)
+	}
+
+	appendLogfmtValue(b, str)
+}
+
+// appendLogfmtKey appends key with every character a key cannot contain replaced by '_'
+func appendLogfmtKey(b *bytes.Buffer, key string) {
+	if key == "" {
+		b.WriteByte('_')
+		return
+	}
+
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || isControl(r) {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+}
+
+// appendLogfmtValue appends value, quoted if it is empty or contains spaces,
+// '=', quotes or control characters, which are escaped
+func appendLogfmtValue(b *bytes.Buffer, value string) {
+	needsQuotes := value == ""
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || isControl(r) {
+			needsQuotes = true
+			break
+		}
+	}
+
+	if !needsQuotes {
+		b.WriteString(value)
+		return
+	}
+
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if isControl(r) {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// ParseLogfmt parses a line of logfmt into its pairs, in order, with string
+// values. A key without '=' has an empty value.
+func ParseLogfmt(line string) ([]Field, error) {
+	var fields []Field
+
+	i := 0
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t' || line[i] == '\n' || line[i] == '\r') {
+			i++
+		}
+		if i == len(line) {
+			return fields, nil
+		}
+
+		start := i
+		for i < len(line) && line[i] > ' ' && line[i] != '=' && line[i] != '"' {
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("logfmt: unexpected %q at %d", line[i], i)
+		}
+
+		field := Field{Key: line[start:i], Value: ""}
+		if i < len(line) && line[i] == '=' {
+			i++
+
+			var value string
+			var err error
+			if i < len(line) && line[i] == '"' {
+				value, i, err = parseLogfmtQuoted(line, i)
+			} else {
+				value, i, err = parseLogfmtBare(line, i)
+			}
+			if err != nil {
+				return nil, err
+			}
+			field.Value = value
+		}
+
+		fields = append(fields, field)
+	}
+}
+
+// parseLogfmtBare parses the bare value at i, returning it and the index after it
+func parseLogfmtBare(line string, i int) (string, int, error) {
+	start := i
+	for i < len(line) && line[i] > ' ' {
+		if line[i] == '=' || line[i] == '"' {
+			return "", i, fmt.Errorf("logfmt: unexpected %q at %d", line[i], i)
+		}
+		i++
+	}
+	return line[start:i], i, nil
+}
+
+// parseLogfmtQuoted parses the quoted value at i, returning it and the index after it
+func parseLogfmtQuoted(line string, i int) (string, int, error) {
+	var b strings.Builder
+
+	for i++; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1, nil
+		case c != '\\':
+			b.WriteByte(c)
+		case i+1 == len(line):
+			return "", i, fmt.Errorf("logfmt: unterminated escape at %d", i)
+		default:
+			i++
+			switch line[i] {
+			case '"', '\\':
+				b.WriteByte(line[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if i+5 > len(line) {
+					return "", i, fmt.Errorf("logfmt: short unicode escape at %d", i)
+				}
+				r, err := strconv.ParseUint(line[i+1:i+5], 16, 16)
+				if err != nil {
+					return "", i, fmt.Errorf("logfmt: invalid unicode escape at %d", i)
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				return "", i, fmt.Errorf("logfmt: invalid escape %q at %d", line[i], i)
+			}
+		}
+	}
+
+	return "", i, fmt.Errorf("logfmt: unterminated quoted value")
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogfmtDrinker(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).All(),
+		Drinker: NewLogfmtDrinker(buf),
+		Clock:   fixedClock(time.Date(2015, 10, 21, 16, 29, 0, 0, time.UTC)),
+	})
+
+	logger.Set("bad key=", "a=b").Set("empty", "").Set("user_name", "marty").
+		WithErrors(errors.New("one"), errors.New("two")).Infof("say \"hi\"\n")
+
+	expected := `time=2015-10-21T16:29:00Z level=Info msg="say \"hi\"\n" bad_key_="a=b" empty="" user_name=marty errors.0=one errors.1=two` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, buf.String())
+	}
+}
+
+func TestLogfmtRoundTrip(t *testing.T) {
+	values := []string{
+		"plain",
+		"",
+		"with space",
+		"a=b",
+		`"quoted"`,
+		`back\slash`,
+		"new\nline\r\ttab",
+		"bell\x07 del\x7f",
+		"unicode ☃",
+	}
+
+	for _, value := range values {
+		buf := new(bytes.Buffer)
+		if err := NewLogfmtDrinker(buf).Drink(map[string]interface{}{"key": value}); err != nil {
+			t.Fatal(err)
+		}
+
+		line := buf.String()
+		if strings.HasSuffix(line, " \n") || strings.Count(line, "\n") != 1 {
+			t.Fatalf("expected a single line without trailing space, got %q", line)
+		}
+
+		fields, err := ParseLogfmt(line)
+		if err != nil {
+			t.Fatalf("%q: %s", line, err)
+		}
+
+		if len(fields) != 1 || fields[0].Key != "key" || fields[0].Value != value {
+			t.Fatalf("expected key=%q, got %v from %q", value, fields, line)
+		}
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	fields, err := ParseLogfmt(`a=1 b="two words" flag c=`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Field{{"a", "1"}, {"b", "two words"}, {"flag", ""}, {"c", ""}}
+	if len(fields) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, fields)
+	}
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected[i], fields[i])
+		}
+	}
+
+	for _, invalid := range []string{`a="open`, `a=b"c`, `=a`, `a="\q"`, `a=b=c`} {
+		if _, err := ParseLogfmt(invalid); err == nil {
+			t.Fatalf("expected error parsing %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LokiConfig is the configuration for LokiDrinker
+type LokiConfig struct {
+	// URL is the push API endpoint, such as http://localhost:3100/loki/api/v1/push
+	URL string
+	// Client pushes batches, a client with a 30 second timeout if nil
+	Client *http.Client
+	// Header is added to each request
+	Header http.Header
+	// TenantID is sent as the X-Scope-OrgID header if set
+	TenantID string
+
+	// Labels are added to every stream, such as the service
+	Labels map[string]string
+	// LabelKeys are the keys of the context fields sent as labels rather than
+	// in the log line. Their values should have a low cardinality, as each
+	// combination of labels is a stream.
+	LabelKeys []string
+
+	// Protobuf pushes batches as snappy compressed protobuf instead of JSON
+	Protobuf bool
+	// Gzip compresses JSON requests with gzip
+	Gzip bool
+
+	Batch BatchConfig
+}
+
+// DefaultLokiConfig creates a default LokiConfig
+func DefaultLokiConfig() *LokiConfig {
+	return &LokiConfig{
+		URL:   "http://localhost:3100/loki/api/v1/push",
+		Gzip:  true,
+		Batch: *DefaultBatchConfig(),
+	}
+}
+
+// LokiDrinker is a Drinker that pushes logs to Grafana Loki in batches.
+// Entries are grouped in streams by their level, the static labels and the
+// context fields of the label keys. The other standard values and context
+// fields are sent in the log line as logfmt.
+type LokiDrinker struct {
+	url      string
+	client   *http.Client
+	header   http.Header
+	labels   map[string]string
+	labelKey map[string]bool
+	protobuf bool
+	gzip     bool
+
+	batcher *batcher
+}
+
+// NewLokiDrinker creates a new Loki Drinker and starts pushing batches
+func NewLokiDrinker(config *LokiConfig) (*LokiDrinker, error) {
+	if config == nil {
+		config = DefaultLokiConfig()
+	}
+
+	if err := checkHTTPURL(config.URL); err != nil {
+		return nil, err
+	}
+
+	drkr := &LokiDrinker{
+		url:      config.URL,
+		client:   config.Client,
+		header:   make(http.Header),
+		labels:   make(map[string]string, len(config.Labels)),
+		labelKey: make(map[string]bool, len(config.LabelKeys)),
+		protobuf: config.Protobuf,
+		gzip:     config.Gzip && !config.Protobuf,
+	}
+
+	if drkr.client == nil {
+		drkr.client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	for key, values := range config.Header {
+		drkr.header[key] = values
+	}
+
+	if config.TenantID != "" {
+		drkr.header.Set("X-Scope-OrgID", config.TenantID)
+	}
+
+	for name, value := range config.Labels {
+		drkr.labels[lokiLabelName(name)] = value
+	}
+
+	for _, key := range config.LabelKeys {
+		drkr.labelKey[key] = true
+	}
+
+	btchr, err := newBatcher(config.Batch, drkr.send)
+	if err != nil {
+		return nil, err
+	}
+
+	drkr.batcher = btchr
+	return drkr, nil
+}
+
+// Drink drinks logs, using the default keys for the standard values
+func (drkr *LokiDrinker) Drink(v map[string]interface{}) error {
+	return drkr.DrinkEntry(entryFromMap(v))
+}
+
+// DrinkEntry queues the log to be pushed, reusing the cached encoding of the
+// entry's fields
+func (drkr *LokiDrinker) DrinkEntry(e *Entry) error {
+	labels := make(map[string]string, len(drkr.labels)+len(drkr.labelKey)+1)
+	for name, value := range drkr.labels {
+		labels[name] = value
+	}
+
+	for _, field := range e.Fields.List() {
+		if drkr.labelKey[field.Key] {
+			labels[lokiLabelName(field.Key)] = fmt.Sprint(field.Value)
+		}
+	}
+	labels["level"] = strings.ToLower(e.Level.String())
+
+	// labels are marshaled with their names sorted, so each stream has one key
+	stream, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteString(strconv.FormatInt(e.Time.UnixNano(), 10))
+
+	// the time and level are the timestamp and a label rather than in the line
+	for _, field := range e.Standard()[2:] {
+		b.WriteByte(' ')
+		appendLogfmt(b, field.Key, field.Value)
+	}
+	b.Write(e.Fields.Encoded(drkr, drkr.encodeFields))
+
+	return drkr.batcher.add(batchItem{key: string(stream), data: b.Bytes()})
+}
+
+// encodeFields encodes the fields that are not labels as logfmt pairs, each
+// preceded by a space
+func (drkr *LokiDrinker) encodeFields(fields []Field) []byte {
+	b := new(bytes.Buffer)
+	for _, field := range fields {
+		if !drkr.labelKey[field.Key] {
+			b.WriteByte(' ')
+			appendLogfmt(b, field.Key, field.Value)
+		}
+	}
+	return b.Bytes()
+}
+
+// Flush pushes the queued logs
+func (drkr *LokiDrinker) Flush() error {
+	return drkr.batcher.flush()
+}
+
+// Close pushes the queued logs and stops pushing. Logs drunk after it are
+// dropped.
+func (drkr *LokiDrinker) Close() error {
+	return drkr.batcher.close()
+}
+
+// lokiStream holds the entries of a stream, each its timestamp in nanoseconds,
+// a space and the line
+type lokiStream struct {
+	labels  string
+	entries [][]byte
+}
+
+// send pushes items, returning them if they should be sent again
+func (drkr *LokiDrinker) send(items []batchItem) ([]batchItem, error) {
+	var streams []*lokiStream
+	index := make(map[string]*lokiStream)
+	for _, item := range items {
+		stream, ok := index[item.key]
+		if !ok {
+			stream = &lokiStream{labels: item.key}
+			index[item.key] = stream
+			streams = append(streams, stream)
+		}
+		stream.entries = append(stream.entries, item.data)
+	}
+
+	var body []byte
+	var err error
+	contentType := "application/json"
+	if drkr.protobuf {
+		body, err = lokiProtobuf(streams)
+		body = snappyEncode(body)
+		contentType = "application/x-protobuf"
+	} else {
+		body, err = lokiJSON(streams)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("dropped %d logs: %v", len(items), err)
+	}
+
+	resp, err := httpPost(drkr.client, drkr.url, drkr.header, contentType, body, drkr.gzip)
+	if err != nil {
+		return items, err
+	}
+	discardBody(resp)
+
+	if err := httpStatusError(resp); err != nil {
+		if httpRetryable(resp.StatusCode) {
+			return items, err
+		}
+		return nil, fmt.Errorf("dropped %d logs: %v", len(items), err)
+	}
+	return nil, nil
+}
+
+// lokiJSON encodes streams as a JSON push request
+func lokiJSON(streams []*lokiStream) ([]byte, error) {
+	b := new(bytes.Buffer)
+	b.WriteString(`{"streams":[`)
+
+	for i, stream := range streams {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(`{"stream":`)
+		b.WriteString(stream.labels)
+		b.WriteString(`,"values":[`)
+
+		for j, entry := range stream.entries {
+			ts, line := splitLokiEntry(entry)
+
+			value, err := json.Marshal([]string{ts, line})
+			if err != nil {
+				return nil, err
+			}
+
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.Write(value)
+		}
+
+		b.WriteString("]}")
+	}
+
+	b.WriteString("]}")
+	return b.Bytes(), nil
+}
+
+// lokiProtobuf encodes streams as a protobuf push request
+func lokiProtobuf(streams []*lokiStream) ([]byte, error) {
+	var req []byte
+	for _, stream := range streams {
+		var labels map[string]string
+		if err := json.Unmarshal([]byte(stream.labels), &labels); err != nil {
+			return nil, err
+		}
+
+		names := make([]string, 0, len(labels))
+		for name := range labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		pairs := make([]string, len(names))
+		for i, name := range names {
+			pairs[i] = name + "=" + strconv.Quote(labels[name])
+		}
+
+		// StreamAdapter: labels = 1, entries = 2
+		msg := appendProtoBytes(nil, 1, []byte("{"+strings.Join(pairs, ", ")+"}"))
+		for _, entry := range stream.entries {
+			ts, line := splitLokiEntry(entry)
+
+			nanos, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			// Timestamp: seconds = 1, nanos = 2
+			var timestamp []byte
+			timestamp = appendProtoVarint(timestamp, 1, uint64(nanos/1e9))
+			timestamp = appendProtoVarint(timestamp, 2, uint64(nanos%1e9))
+
+			// EntryAdapter: timestamp = 1, line = 2
+			e := appendProtoBytes(nil, 1, timestamp)
+			e = appendProtoBytes(e, 2, []byte(line))
+
+			msg = appendProtoBytes(msg, 2, e)
+		}
+
+		// PushRequest: streams = 1
+		req = appendProtoBytes(req, 1, msg)
+	}
+
+	return req, nil
+}
+
+// splitLokiEntry splits an entry into its timestamp and line
+func splitLokiEntry(entry []byte) (string, string) {
+	i := bytes.IndexByte(entry, ' ')
+	if i < 0 {
+		return string(entry), ""
+	}
+	return string(entry[:i]), string(entry[i+1:])
+}
+
+// appendProtoVarint appends a varint field
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendProtoBytes appends a length delimited field
+func appendProtoBytes(b []byte, field int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// lokiLabelName returns name as a label name: letters, digits and '_', not
+// starting with a digit, with all other characters replaced by '_'
+func lokiLabelName(name string) string {
+	b := new(strings.Builder)
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || i > 0 && r >= '0' && r <= '9') {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
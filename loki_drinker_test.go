@@ -0,0 +1,207 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// lokiPush is a push request as received by a test server
+type lokiPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][]string        `json:"values"`
+	} `json:"streams"`
+}
+
+func newLokiServer(t *testing.T, handle func(r *http.Request, body []byte)) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := readRequestBody(r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		handle(r, data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newLokiLager(t *testing.T, config *LokiConfig) (ContextLager, *LokiDrinker) {
+	config.Batch = testBatchConfig()
+
+	drinker, err := NewLokiDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestLager(t, drinker), drinker
+}
+
+func TestLokiDrinker(t *testing.T) {
+	var push lokiPush
+	var tenant string
+	srv := newLokiServer(t, func(r *http.Request, body []byte) {
+		tenant = r.Header.Get("X-Scope-OrgID")
+		if err := json.Unmarshal(body, &push); err != nil {
+			t.Error(err)
+		}
+	})
+
+	config := DefaultLokiConfig()
+	config.URL = srv.URL
+	config.TenantID = "hill-valley"
+	config.Labels = map[string]string{"service": "lager"}
+	config.LabelKeys = []string{"region"}
+	logger, drinker := newLokiLager(t, config)
+
+	east := logger.Child().Set("region", "east")
+	east.Child().Set("user", "marty mcfly").Infof("first")
+	logger.Child().Set("region", "west").Infof("second")
+	east.Infof("third")
+	east.Errorf("fourth")
+
+	if err := drinker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tenant != "hill-valley" {
+		t.Fatalf("expected the tenant header, got %q", tenant)
+	}
+
+	ts := "1445444940123000000"
+	expected := lokiPush{}
+	json.Unmarshal([]byte(`{"streams":[
+		{"stream":{"level":"info","region":"east","service":"lager"},"values":[
+			["`+ts+`","msg=first user=\"marty mcfly\""],
+			["`+ts+`","msg=third"]]},
+		{"stream":{"level":"info","region":"west","service":"lager"},"values":[["`+ts+`","msg=second"]]},
+		{"stream":{"level":"error","region":"east","service":"lager"},"values":[["`+ts+`","msg=fourth"]]}
+	]}`), &expected)
+
+	if !reflect.DeepEqual(push, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, push)
+	}
+}
+
+// readProto reads the fields of a protobuf message, as varints or bytes
+func readProto(data []byte) (map[int][]interface{}, error) {
+	fields := make(map[int][]interface{})
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid tag")
+		}
+		data = data[n:]
+
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid varint")
+		}
+		data = data[n:]
+
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0:
+			fields[field] = append(fields[field], v)
+		case 2:
+			fields[field] = append(fields[field], data[:v])
+			data = data[v:]
+		default:
+			return nil, errors.New("unexpected wire type")
+		}
+	}
+	return fields, nil
+}
+
+func TestLokiDrinkerProtobuf(t *testing.T) {
+	var contentType string
+	var req []byte
+	srv := newLokiServer(t, func(r *http.Request, body []byte) {
+		contentType = r.Header.Get("Content-Type")
+		req = body
+	})
+
+	config := DefaultLokiConfig()
+	config.URL = srv.URL
+	config.Protobuf = true
+	logger, drinker := newLokiLager(t, config)
+
+	logger.Warnf("hello")
+
+	if err := drinker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != "application/x-protobuf" {
+		t.Fatalf("expected protobuf, got %s", contentType)
+	}
+
+	data, err := snappyDecode(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	push, err := readProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := readProto(push[1][0].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := string(stream[1][0].([]byte)); labels != `{level="warn"}` {
+		t.Fatalf(`expected {level="warn"}, got %s`, labels)
+	}
+
+	entry, err := readProto(stream[2][0].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := string(entry[2][0].([]byte)); line != "msg=hello" {
+		t.Fatalf("expected msg=hello, got %s", line)
+	}
+
+	timestamp, err := readProto(entry[1][0].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seconds := timestamp[1][0].(uint64); seconds != uint64(testTime.Unix()) {
+		t.Fatalf("expected %d seconds, got %d", testTime.Unix(), seconds)
+	}
+}
+
+func TestLokiLabelName(t *testing.T) {
+	names := map[string]string{
+		"service":  "service",
+		"k8s.pod":  "k8s_pod",
+		"2fa":      "_fa",
+		"trace-id": "trace_id",
+		"":         "_",
+	}
+
+	for name, expected := range names {
+		if actual := lokiLabelName(name); actual != expected {
+			t.Fatalf("expected '%s' for '%s', got '%s'", expected, name, actual)
+		}
+	}
+}
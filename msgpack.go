@@ -0,0 +1,383 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// msgpackExt is a MessagePack extension value
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// appendMsgpack appends v encoded as MessagePack. Values of other types than
+// nil, booleans, numbers, strings, byte slices, slices, maps, time.Time and
+// errors are encoded as their JSON form would be, or as their string form.
+func appendMsgpack(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if v {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case int:
+		return appendMsgpackInt(b, int64(v))
+	case int8:
+		return appendMsgpackInt(b, int64(v))
+	case int16:
+		return appendMsgpackInt(b, int64(v))
+	case int32:
+		return appendMsgpackInt(b, int64(v))
+	case int64:
+		return appendMsgpackInt(b, v)
+	case uint:
+		return appendMsgpackUint(b, uint64(v))
+	case uint8:
+		return appendMsgpackUint(b, uint64(v))
+	case uint16:
+		return appendMsgpackUint(b, uint64(v))
+	case uint32:
+		return appendMsgpackUint(b, uint64(v))
+	case uint64:
+		return appendMsgpackUint(b, v)
+	case float32:
+		b = append(b, 0xca)
+		return binary.BigEndian.AppendUint32(b, math.Float32bits(v))
+	case float64:
+		b = append(b, 0xcb)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	case string:
+		return appendMsgpackString(b, v)
+	case []byte:
+		return appendMsgpackBin(b, v)
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(v))
+		for _, elem := range v {
+			b = appendMsgpack(b, elem)
+		}
+		return b
+	case map[string]interface{}:
+		b = appendMsgpackMapHeader(b, len(v))
+		for key, elem := range v {
+			b = appendMsgpackString(b, key)
+			b = appendMsgpack(b, elem)
+		}
+		return b
+	case time.Time:
+		return appendMsgpackEventTime(b, v)
+	case msgpackExt:
+		return appendMsgpackExt(b, v)
+	case error:
+		return appendMsgpackString(b, v.Error())
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return appendMsgpackString(b, fmt.Sprint(v))
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return appendMsgpackString(b, fmt.Sprint(v))
+	}
+	return appendMsgpack(b, decoded)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+	}
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackBin(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, data...)
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+func appendMsgpackExt(b []byte, ext msgpackExt) []byte {
+	switch n := len(ext.Data); n {
+	case 1:
+		b = append(b, 0xd4)
+	case 2:
+		b = append(b, 0xd5)
+	case 4:
+		b = append(b, 0xd6)
+	case 8:
+		b = append(b, 0xd7)
+	case 16:
+		b = append(b, 0xd8)
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc7, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xc8), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xc9), uint32(n))
+		}
+	}
+	b = append(b, byte(ext.Type))
+	return append(b, ext.Data...)
+}
+
+// appendMsgpackEventTime appends t as a Fluentd EventTime, extension type 0
+// holding the seconds and nanoseconds
+func appendMsgpackEventTime(b []byte, t time.Time) []byte {
+	data := make([]byte, 0, 8)
+	data = binary.BigEndian.AppendUint32(data, uint32(t.Unix()))
+	data = binary.BigEndian.AppendUint32(data, uint32(t.Nanosecond()))
+	return appendMsgpackExt(b, msgpackExt{Type: 0, Data: data})
+}
+
+// readMsgpack reads a MessagePack value from r. Maps are read as
+// map[string]interface{}, arrays as []interface{}, integers as int64 or
+// uint64, strings as string, binaries as []byte and extensions as msgpackExt.
+func readMsgpack(r io.Reader) (interface{}, error) {
+	c, err := readMsgpackBytes(r, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch code := c[0]; {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return readMsgpackMap(r, int(code&0x0f))
+	case code&0xf0 == 0x90:
+		return readMsgpackArray(r, int(code&0x0f))
+	case code&0xe0 == 0xa0:
+		data, err := readMsgpackBytes(r, int(code&0x1f))
+		return string(data), err
+	}
+
+	switch code := c[0]; code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgpackLength(r, 1<<(code-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackBytes(r, n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readMsgpackLength(r, 1<<(code-0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackExt(r, n)
+	case 0xca:
+		data, err := readMsgpackBytes(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case 0xcb:
+		data, err := readMsgpackBytes(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readMsgpackLength(r, 1<<(code-0xcc))
+		return uint64(n), err
+	case 0xd0:
+		data, err := readMsgpackBytes(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int8(data[0])), nil
+	case 0xd1:
+		data, err := readMsgpackBytes(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int16(binary.BigEndian.Uint16(data))), nil
+	case 0xd2:
+		data, err := readMsgpackBytes(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int32(binary.BigEndian.Uint32(data))), nil
+	case 0xd3:
+		data, err := readMsgpackBytes(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(data)), nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readMsgpackExt(r, 1<<(code-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgpackLength(r, 1<<(code-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		data, err := readMsgpackBytes(r, n)
+		return string(data), err
+	case 0xdc, 0xdd:
+		n, err := readMsgpackLength(r, 2<<(code-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, n)
+	case 0xde, 0xdf:
+		n, err := readMsgpackLength(r, 2<<(code-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, n)
+	}
+
+	return nil, fmt.Errorf("msgpack: invalid code 0x%x", c[0])
+}
+
+func readMsgpackBytes(r io.Reader, n int) ([]byte, error) {
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// readMsgpackLength reads a big endian unsigned integer of size bytes
+func readMsgpackLength(r io.Reader, size int) (int, error) {
+	data, err := readMsgpackBytes(r, size)
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, c := range data {
+		n = n<<8 | uint64(c)
+	}
+	return int(n), nil
+}
+
+func readMsgpackExt(r io.Reader, n int) (interface{}, error) {
+	data, err := readMsgpackBytes(r, n+1)
+	if err != nil {
+		return nil, err
+	}
+	return msgpackExt{Type: int8(data[0]), Data: data[1:]}, nil
+}
+
+func readMsgpackArray(r io.Reader, n int) (interface{}, error) {
+	array := make([]interface{}, n)
+	for i := range array {
+		elem, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		array[i] = elem
+	}
+	return array, nil
+}
+
+func readMsgpackMap(r io.Reader, n int) (interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+	}
+	return m, nil
+}
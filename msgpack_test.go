@@ -0,0 +1,87 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	values := []struct {
+		in, out interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{false, false},
+		{7, int64(7)},
+		{200, uint64(200)},
+		{70000, uint64(70000)},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{-5, int64(-5)},
+		{-100, int64(-100)},
+		{-1000, int64(-1000)},
+		{int64(math.MinInt64), int64(math.MinInt64)},
+		{float32(1.5), 1.5},
+		{2.25, 2.25},
+		{"lager", "lager"},
+		{strings.Repeat("x", 300), strings.Repeat("x", 300)},
+		{strings.Repeat("y", 70000), strings.Repeat("y", 70000)},
+		{[]byte("bin"), []byte("bin")},
+		{[]interface{}{1, "a"}, []interface{}{int64(1), "a"}},
+		{map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "b"}},
+		{Frame{Func: "main", File: "main.go", Line: 3}, map[string]interface{}{"func": "main", "file": "main.go", "line": 3.0}},
+		{errors.New("boom"), "boom"},
+	}
+
+	for _, v := range values {
+		data := appendMsgpack(nil, v.in)
+
+		out, err := readMsgpack(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(out, v.out) {
+			t.Fatalf("expected %#v, got %#v", v.out, out)
+		}
+	}
+}
+
+func TestMsgpackEventTime(t *testing.T) {
+	data := appendMsgpack(nil, time.Unix(1445444940, 123))
+
+	out, err := readMsgpack(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext, ok := out.(msgpackExt)
+	if !ok || ext.Type != 0 || len(ext.Data) != 8 {
+		t.Fatalf("expected an EventTime, got %#v", out)
+	}
+
+	if sec := binary.BigEndian.Uint32(ext.Data); sec != 1445444940 {
+		t.Fatalf("expected seconds 1445444940, got %d", sec)
+	}
+	if nsec := binary.BigEndian.Uint32(ext.Data[4:]); nsec != 123 {
+		t.Fatalf("expected nanoseconds 123, got %d", nsec)
+	}
+}
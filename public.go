@@ -33,35 +33,109 @@ func SetStacktraces(on bool) ContextLager {
 	return defaultLager
 }
 
+// SetStacktraceLevels sets the levels that capture stacktraces, such as Warn|Error.
+// Zero means Error, as in ContextConfig.
+func SetStacktraceLevels(levels Level) ContextLager {
+	if levels == 0 {
+		levels = Error
+	}
+	defaultLager.(*contextLager).stackLevels = levels
+	return defaultLager
+}
+
 // SetFileType sets the fileType that is used for logs.
 func SetFileType(fileType FileType) ContextLager {
 	defaultLager.(*contextLager).fileType = fileType
 	return defaultLager
 }
 
+// SetFieldOrder sets the order context fields are logged in.
+func SetFieldOrder(order FieldOrder) ContextLager {
+	defaultLager.(*contextLager).fieldOrder = order
+	return defaultLager
+}
+
+// SetKeyPolicy sets how context fields with reserved keys are handled.
+func SetKeyPolicy(policy KeyPolicy) ContextLager {
+	defaultLager.(*contextLager).keyPolicy = policy
+	return defaultLager
+}
+
+// SetFormat sets the keys and formatting of the standard values of logs.
+// Keys and the time layout left empty are those of DefaultFormat.
+func SetFormat(format *Format) ContextLager {
+	defaultLager.(*contextLager).format = format.withDefaults()
+	return defaultLager
+}
+
+// SetClock sets the clock that tells the time of logs.
+func SetClock(clock Clock) ContextLager {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	defaultLager.(*contextLager).clock = clock
+	return defaultLager
+}
+
+// SetErrorHandler sets the function called with errors from logging.
+func SetErrorHandler(handler func(error)) ContextLager {
+	defaultLager.(*contextLager).errorHandler = handler
+	return defaultLager
+}
+
 // Tracef logs with level Trace using the package lager.
 func Tracef(msg string, v ...interface{}) {
-	defaultLager.Tracef(msg, v...)
+	defaultLager.(*contextLager).logf(1, Trace, msg, v...)
 }
 
 // Debugf logs with level Debug using the package lager.
 func Debugf(msg string, v ...interface{}) {
-	defaultLager.Debugf(msg, v...)
+	defaultLager.(*contextLager).logf(1, Debug, msg, v...)
 }
 
 // Infof logs with level Info using the package lager.
 func Infof(msg string, v ...interface{}) {
-	defaultLager.Infof(msg, v...)
+	defaultLager.(*contextLager).logf(1, Info, msg, v...)
 }
 
 // Warnf logs with level Warn using the package lager.
 func Warnf(msg string, v ...interface{}) {
-	defaultLager.Warnf(msg, v...)
+	defaultLager.(*contextLager).logf(1, Warn, msg, v...)
 }
 
 // Errorf logs with level Error using the package lager.
 func Errorf(msg string, v ...interface{}) {
-	defaultLager.Errorf(msg, v...)
+	defaultLager.(*contextLager).logf(1, Error, msg, v...)
+}
+
+// Enabled returns whether logs with level are written by the package lager.
+func Enabled(level Level) bool {
+	return defaultLager.Enabled(level)
+}
+
+// TraceFn logs the result of fn with level Trace using the package lager, calling fn only if enabled.
+func TraceFn(fn LogFunc) {
+	defaultLager.(*contextLager).logFn(1, Trace, fn)
+}
+
+// DebugFn logs the result of fn with level Debug using the package lager, calling fn only if enabled.
+func DebugFn(fn LogFunc) {
+	defaultLager.(*contextLager).logFn(1, Debug, fn)
+}
+
+// InfoFn logs the result of fn with level Info using the package lager, calling fn only if enabled.
+func InfoFn(fn LogFunc) {
+	defaultLager.(*contextLager).logFn(1, Info, fn)
+}
+
+// WarnFn logs the result of fn with level Warn using the package lager, calling fn only if enabled.
+func WarnFn(fn LogFunc) {
+	defaultLager.(*contextLager).logFn(1, Warn, fn)
+}
+
+// ErrorFn logs the result of fn with level Error using the package lager, calling fn only if enabled.
+func ErrorFn(fn LogFunc) {
+	defaultLager.(*contextLager).logFn(1, Error, fn)
 }
 
 // With adds key values to the returned lager using the package lager.
@@ -74,13 +148,37 @@ func WithError(err error) ContextLager {
 	return defaultLager.WithError(err)
 }
 
+// WithNamedError adds an error under key to the returned lager if non nil, using the package lager.
+func WithNamedError(key string, err error) ContextLager {
+	return defaultLager.WithNamedError(key, err)
+}
+
+// WithErrors adds the non nil errors to the list of errors of the returned lager, using the package lager.
+func WithErrors(errs ...error) ContextLager {
+	return defaultLager.WithErrors(errs...)
+}
+
 // Set sets a key to value in the lager map  using the package lager.
 func Set(key, value string) ContextLager {
 	return defaultLager.Set(key, value)
 }
 
+// SetLazy sets a key to a value that is only evaluated when a log is written, using the package lager.
+func SetLazy(key string, value Valuer) ContextLager {
+	return defaultLager.SetLazy(key, value)
+}
+
 // Child creates a child ContextLager using the package lager as the parent.
 // The child inherits all the parent values.
 func Child() ContextLager {
 	return defaultLager.Child()
 }
+
+// Recover logs a panic using the package lager. It must be deferred:
+//
+//	defer lager.Recover(nil)
+func Recover(opts *RecoverOptions) {
+	if v := recover(); v != nil {
+		defaultLager.(*contextLager).handlePanic(v, opts)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"runtime"
+	"strings"
+)
+
+// RecoverOptions configures how Recover handles a panic
+type RecoverOptions struct {
+	// Level is the level the panic is logged at, Error if zero
+	Level Level
+	// Repanic panics again with the recovered value once it is logged
+	Repanic bool
+	// OnPanic is called with the recovered value once it is logged
+	OnPanic func(v interface{})
+}
+
+// Recover logs a panic along with the stack of the panicking goroutine, then
+// flushes the drinker if it is a Flusher. It must be deferred:
+//
+//	defer lgr.Recover(nil)
+func (lgr *contextLager) Recover(opts *RecoverOptions) {
+	if v := recover(); v != nil {
+		lgr.handlePanic(v, opts)
+	}
+}
+
+// handlePanic handles the value v recovered by the function deferred in the
+// panicking goroutine that called it
+func (lgr *contextLager) handlePanic(v interface{}, opts *RecoverOptions) {
+	if opts == nil {
+		opts = new(RecoverOptions)
+	}
+
+	lvl := opts.Level
+	if lvl == 0 {
+		lvl = Error
+	}
+
+	if lgr.Enabled(lvl) {
+		plgr := lgr
+		if err, ok := v.(error); ok {
+			plgr = lgr.WithError(err).(*contextLager)
+		}
+
+		// the stack is logged even if the lager does not capture stacktraces
+		if key := plgr.format.Keys.Stacktrace; !plgr.reserved(key) {
+			plgr = plgr.child()
+			plgr.fields = plgr.fields.without(key)
+		}
+
+		e := plgr.newEntry(lvl, "panic: %v", v)
+
+		// skip handlePanic, Recover and the runtime's panic frames,
+		// so the stack starts where the panic happened
+		e.Stack = captureStack(2, 0)
+		for len(e.Stack) > 1 && strings.HasPrefix(e.Stack[0].Func, "runtime.") {
+			e.Stack = e.Stack[1:]
+		}
+
+		if len(e.Stack) > 0 {
+			frame := e.Stack[0]
+			e.File = lgr.fileType.Format(runtime.Frame{
+				Function: frame.Func,
+				File:     frame.File,
+				Line:     frame.Line,
+			})
+
+			if lgr.funcName {
+				e.Func = frame.Func
+			}
+		}
+
+		plgr.drink(e)
+	}
+
+	if flusher, ok := lgr.drinker.(Flusher); ok {
+		lgr.handleError(flusher.Flush())
+	}
+
+	if opts.OnPanic != nil {
+		opts.OnPanic(v)
+	}
+
+	if opts.Repanic {
+		panic(v)
+	}
+}
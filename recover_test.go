@@ -0,0 +1,152 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type flushingDrinker struct {
+	Drinker
+	flushed int
+}
+
+func (drkr *flushingDrinker) Flush() error {
+	drkr.flushed++
+	return nil
+}
+
+func panicky() {
+	panic(errors.New("boom"))
+}
+
+func TestRecover(t *testing.T) {
+	buf := new(bytes.Buffer)
+	drinker := &flushingDrinker{Drinker: NewJSONDrinker(buf)}
+
+	format := DefaultFormat()
+	format.StructuredStacktraces = true
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:   new(Levels).Set(Error),
+		Drinker:  drinker,
+		FileType: ShortFile,
+		Format:   format,
+	})
+
+	var recovered interface{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer logger.Recover(&RecoverOptions{
+			OnPanic: func(v interface{}) { recovered = v },
+		})
+		panicky()
+	}()
+	<-done
+
+	if err, ok := recovered.(error); !ok || err.Error() != "boom" {
+		t.Fatalf("expected OnPanic with boom, got %v", recovered)
+	}
+
+	if drinker.flushed != 1 {
+		t.Fatalf("expected drinker to be flushed once, got %d", drinker.flushed)
+	}
+
+	var actual struct {
+		Msg        string    `json:"msg"`
+		File       string    `json:"file"`
+		Error      ErrorInfo `json:"error"`
+		Stacktrace Stack     `json:"stacktrace"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Msg != "panic: boom" || actual.Error.Message != "boom" {
+		t.Fatalf("expected panic: boom with error boom, got %s and %s", actual.Msg, actual.Error.Message)
+	}
+
+	if len(actual.Stacktrace) == 0 || actual.Stacktrace[0].Func != "github.com/doubledutch/lager.panicky" {
+		t.Fatalf("expected stacktrace to start at panicky, got %v", actual.Stacktrace)
+	}
+
+	if !strings.HasPrefix(actual.File, "recover_test.go:") {
+		t.Fatalf("expected file recover_test.go, got %s", actual.File)
+	}
+}
+
+func TestRecoverRepanic(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Warn),
+		Drinker: NewJSONDrinker(buf),
+	})
+
+	var repanicked interface{}
+	func() {
+		defer func() {
+			repanicked = recover()
+		}()
+		defer logger.Recover(&RecoverOptions{Level: Warn, Repanic: true})
+		panic("again")
+	}()
+
+	if repanicked != "again" {
+		t.Fatalf("expected repanic with again, got %v", repanicked)
+	}
+
+	if !strings.Contains(buf.String(), `"level":"Warn","msg":"panic: again"`) {
+		t.Fatalf("expected Warn panic log, got '%s'", buf.String())
+	}
+}
+
+func TestRecoverNoPanic(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	logger := NewContextLager(&ContextConfig{
+		Levels:  new(Levels).Set(Error),
+		Drinker: NewJSONDrinker(buf),
+	})
+
+	func() {
+		defer logger.Recover(nil)
+	}()
+
+	if buf.Len() != 0 {
+		t.Fatalf("expected no logs, got '%s'", buf.String())
+	}
+}
+
+func TestPackageRecover(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	drinker := defaultLager.(*contextLager).drinker
+	defer SetDrinker(drinker)
+	SetDrinker(NewJSONDrinker(buf))
+
+	func() {
+		defer Recover(nil)
+		panic("package")
+	}()
+
+	if !strings.Contains(buf.String(), `"msg":"panic: package"`) {
+		t.Fatalf("expected panic log, got '%s'", buf.String())
+	}
+}
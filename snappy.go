@@ -0,0 +1,91 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import "encoding/binary"
+
+const (
+	snappyTableBits = 14
+	snappyMaxOffset = 1<<16 - 1
+)
+
+// snappyEncode compresses src in the snappy block format
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)/2+16), uint64(len(src)))
+
+	var table [1 << snappyTableBits]int
+	literal := 0
+	for i := 0; i+4 <= len(src); {
+		word := binary.LittleEndian.Uint32(src[i:])
+		h := (word * 0x1e35a7bd) >> (32 - snappyTableBits)
+
+		// table holds positions plus one, so zero means none
+		candidate := table[h] - 1
+		table[h] = i + 1
+
+		if candidate < 0 || i-candidate > snappyMaxOffset ||
+			binary.LittleEndian.Uint32(src[candidate:]) != word {
+			i++
+			continue
+		}
+
+		n := 4
+		for i+n < len(src) && src[candidate+n] == src[i+n] {
+			n++
+		}
+
+		dst = appendSnappyLiteral(dst, src[literal:i])
+		dst = appendSnappyCopy(dst, i-candidate, n)
+		i += n
+		literal = i
+	}
+
+	return appendSnappyLiteral(dst, src[literal:])
+}
+
+// appendSnappyLiteral appends a literal element holding lit
+func appendSnappyLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+
+	switch n := uint32(len(lit) - 1); {
+	case n < 60:
+		dst = append(dst, byte(n<<2))
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// appendSnappyCopy appends copy elements with 2 byte offsets, each copying up
+// to 64 bytes from offset bytes back
+func appendSnappyCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > 64 {
+			n = 64
+		}
+
+		dst = append(dst, byte(n-1)<<2|2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+	return dst
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// snappyDecode decompresses src from the snappy block format
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errors.New("snappy: invalid length")
+	}
+	src = src[n:]
+
+	dst := make([]byte, 0, length)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			size := int(tag >> 2)
+			src = src[1:]
+			if size >= 60 {
+				extra := size - 59
+				if len(src) < extra {
+					return nil, errors.New("snappy: short literal length")
+				}
+				size = 0
+				for i := extra - 1; i >= 0; i-- {
+					size = size<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			size++
+
+			if len(src) < size {
+				return nil, errors.New("snappy: short literal")
+			}
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errors.New("snappy: short copy")
+			}
+			size := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			src = src[3:]
+
+			if offset == 0 || offset > len(dst) {
+				return nil, errors.New("snappy: invalid offset")
+			}
+			for i := 0; i < size; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			return nil, errors.New("snappy: unsupported element")
+		}
+	}
+
+	if uint64(len(dst)) != length {
+		return nil, errors.New("snappy: invalid length")
+	}
+	return dst, nil
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("abc"),
+		[]byte(strings.Repeat("a", 1000)),
+		[]byte(strings.Repeat("level=info msg=hello user=marty ", 500)),
+		random,
+	}
+
+	for _, input := range inputs {
+		encoded := snappyEncode(input)
+
+		decoded, err := snappyDecode(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("expected %d bytes to round trip", len(input))
+		}
+	}
+}
+
+func TestSnappyCompresses(t *testing.T) {
+	input := []byte(strings.Repeat("level=info msg=hello user=marty ", 500))
+	if encoded := snappyEncode(input); len(encoded) > len(input)/10 {
+		t.Fatalf("expected repetitive input to compress, got %d of %d bytes", len(encoded), len(input))
+	}
+}
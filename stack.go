@@ -0,0 +1,86 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+)
+
+// Frame is a single frame of a stacktrace
+type Frame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// Stack is a stacktrace, innermost frame first
+type Stack []Frame
+
+// captureStack returns the stack starting calldepth frames above its caller,
+// counting as runtime.Caller does, with at most depth frames if depth > 0.
+func captureStack(calldepth, depth int) Stack {
+	size := depth
+	if size <= 0 {
+		size = 32
+	}
+
+	var pcs []uintptr
+	for {
+		pcs = make([]uintptr, size)
+		n := runtime.Callers(calldepth+2, pcs)
+		if n < size || depth > 0 {
+			pcs = pcs[:n]
+			break
+		}
+		size *= 2
+	}
+
+	return framesStack(pcs, depth)
+}
+
+// framesStack returns the stack of the program counters pcs, as returned by
+// runtime.Callers, with at most depth frames if depth > 0.
+func framesStack(pcs []uintptr, depth int) Stack {
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	stack := make(Stack, 0, len(pcs))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, Frame{
+			Func: frame.Function,
+			File: frame.File,
+			Line: frame.Line,
+		})
+
+		if !more || (depth > 0 && len(stack) == depth) {
+			break
+		}
+	}
+
+	return stack
+}
+
+// String formats the stack as runtime/debug.Stack does, without the goroutine header
+func (s Stack) String() string {
+	b := new(bytes.Buffer)
+	for _, frame := range s {
+		fmt.Fprintf(b, "%s()\n\t%s:%d\n", frame.Func, frame.File, frame.Line)
+	}
+	return b.String()
+}
@@ -0,0 +1,431 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SyslogFormat represents the format of syslog messages
+type SyslogFormat uint8
+
+const (
+	// RFC5424 formats messages as RFC 5424, with context fields as structured data
+	RFC5424 SyslogFormat = iota
+	// RFC3164 formats messages in the legacy BSD format, with context fields
+	// appended to the message as logfmt
+	RFC3164
+)
+
+// SyslogFacility is a syslog facility code
+type SyslogFacility uint8
+
+// Syslog facilities
+const (
+	FacilityKern SyslogFacility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	_
+	_
+	_
+	_
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// syslogSeverities are the syslog severities of the levels
+var syslogSeverities = map[Level]int{
+	Trace: 7, // debug
+	Debug: 7, // debug
+	Info:  6, // informational
+	Warn:  4, // warning
+	Error: 3, // error
+}
+
+// syslogSockets are the paths of the local syslog socket tried in order
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogConfig is the configuration for SyslogDrinker
+type SyslogConfig struct {
+	// Network is "unixgram", "unix", "udp" or "tcp". If empty, the local syslog
+	// socket is used, or the unix socket at Address if set.
+	Network string
+	// Address is the host:port of the syslog server, or the path of the unix socket
+	Address string
+
+	Format   SyslogFormat
+	Facility SyslogFacility
+
+	// AppName identifies the program, the name of the executable if empty
+	AppName string
+	// Hostname identifies the machine, os.Hostname if empty
+	Hostname string
+	// SDID is the ID of the RFC 5424 structured data element of context fields
+	SDID string
+}
+
+// DefaultSyslogConfig creates a default SyslogConfig
+func DefaultSyslogConfig() *SyslogConfig {
+	return &SyslogConfig{
+		Format:   RFC5424,
+		Facility: FacilityUser,
+		SDID:     "lager@32473",
+	}
+}
+
+// SyslogDrinker is a Drinker that sends logs to syslog. Logs are sent over
+// unixgram sockets and UDP as datagrams, over unix stream sockets ending with
+// a newline, and over TCP with octet counting framing.
+// The connection is reestablished if sending fails.
+type SyslogDrinker struct {
+	network string
+	address string
+
+	format   SyslogFormat
+	facility SyslogFacility
+	appName  string
+	hostname string
+	sdID     string
+	pid      string
+
+	lock    sync.Mutex
+	conn    net.Conn
+	framing syslogFraming
+}
+
+// syslogFraming represents how messages are separated on a connection
+type syslogFraming uint8
+
+const (
+	// syslogDatagrams sends each message as a datagram
+	syslogDatagrams syslogFraming = iota
+	// syslogOctetCounting prefixes each message with its length, for TCP
+	syslogOctetCounting
+	// syslogNewlines ends each message with a newline, as local syslog
+	// daemons expect on unix stream sockets
+	syslogNewlines
+)
+
+// NewSyslogDrinker creates a new Syslog Drinker and connects it to syslog
+func NewSyslogDrinker(config *SyslogConfig) (*SyslogDrinker, error) {
+	if config == nil {
+		config = DefaultSyslogConfig()
+	}
+
+	drkr := &SyslogDrinker{
+		network:  config.Network,
+		address:  config.Address,
+		format:   config.Format,
+		facility: config.Facility,
+		appName:  config.AppName,
+		hostname: config.Hostname,
+		sdID:     syslogName(config.SDID),
+		pid:      strconv.Itoa(os.Getpid()),
+	}
+
+	if drkr.appName == "" {
+		drkr.appName = filepath.Base(os.Args[0])
+	}
+
+	if drkr.hostname == "" {
+		drkr.hostname, _ = os.Hostname()
+	}
+
+	if err := drkr.dial(); err != nil {
+		return nil, err
+	}
+
+	return drkr, nil
+}
+
+// dial connects to syslog
+func (drkr *SyslogDrinker) dial() error {
+	conn, network, err := dialSyslog(drkr.network, drkr.address)
+	if err != nil {
+		return err
+	}
+
+	drkr.conn = conn
+	switch {
+	case strings.HasPrefix(network, "tcp"):
+		drkr.framing = syslogOctetCounting
+	case network == "unix":
+		drkr.framing = syslogNewlines
+	default:
+		drkr.framing = syslogDatagrams
+	}
+	return nil
+}
+
+// dialSyslog connects to syslog at address over network, or to the local
+// syslog socket if network is empty, returning the network connected over
+func dialSyslog(network, address string) (net.Conn, string, error) {
+	if network != "" {
+		conn, err := net.Dial(network, address)
+		return conn, network, err
+	}
+
+	paths := syslogSockets
+	if address != "" {
+		paths = []string{address}
+	}
+
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				return conn, network, nil
+			}
+		}
+	}
+
+	return nil, "", errors.New("syslog: no local syslog socket found")
+}
+
+// Drink drinks logs, using the default keys for the standard values
+func (drkr *SyslogDrinker) Drink(v map[string]interface{}) error {
+	return drkr.DrinkEntry(entryFromMap(v))
+}
+
+// DrinkEntry drinks logs, reusing the cached encoding of the entry's fields
+func (drkr *SyslogDrinker) DrinkEntry(e *Entry) error {
+	var msg []byte
+	if drkr.format == RFC3164 {
+		msg = drkr.rfc3164(e)
+	} else {
+		msg = drkr.rfc5424(e)
+	}
+
+	return drkr.write(msg)
+}
+
+// Close closes the connection to syslog
+func (drkr *SyslogDrinker) Close() error {
+	drkr.lock.Lock()
+	defer drkr.lock.Unlock()
+
+	if drkr.conn == nil {
+		return nil
+	}
+
+	err := drkr.conn.Close()
+	drkr.conn = nil
+	return err
+}
+
+// write sends msg, reconnecting and sending it again once if it fails.
+// Messages sent over TCP are framed by octet counting, and those sent over
+// unix streams end with a newline.
+func (drkr *SyslogDrinker) write(msg []byte) error {
+	drkr.lock.Lock()
+	defer drkr.lock.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if drkr.conn == nil {
+			if err = drkr.dial(); err != nil {
+				continue
+			}
+		}
+
+		data := msg
+		switch drkr.framing {
+		case syslogOctetCounting:
+			data = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		case syslogNewlines:
+			data = append(msg[:len(msg):len(msg)], '\n')
+		}
+
+		if _, err = drkr.conn.Write(data); err == nil {
+			return nil
+		}
+
+		drkr.conn.Close()
+		drkr.conn = nil
+	}
+
+	return err
+}
+
+// priority returns the PRI part of a message logged at lvl
+func (drkr *SyslogDrinker) priority(lvl Level) string {
+	severity, ok := syslogSeverities[lvl]
+	if !ok {
+		severity = syslogSeverities[Error]
+	}
+
+	return "<" + strconv.Itoa(int(drkr.facility)*8+severity) + ">"
+}
+
+// rfc5424 formats e as an RFC 5424 message
+func (drkr *SyslogDrinker) rfc5424(e *Entry) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString(drkr.priority(e.Level))
+	b.WriteString("1 ")
+	b.WriteString(e.Time.Format("2006-01-02T15:04:05.999999Z07:00"))
+	b.WriteByte(' ')
+	b.WriteString(syslogHeader(drkr.hostname, 255))
+	b.WriteByte(' ')
+	b.WriteString(syslogHeader(drkr.appName, 48))
+	b.WriteByte(' ')
+	b.WriteString(drkr.pid)
+	b.WriteString(" - ")
+
+	// the standard values after time, level and message are parameters too
+	params := new(bytes.Buffer)
+	for _, field := range e.Standard()[3:] {
+		appendSDParam(params, field.Key, field.Value)
+	}
+	params.Write(e.Fields.Encoded(drkr, drkr.encodeFields))
+
+	if params.Len() == 0 {
+		b.WriteByte('-')
+	} else {
+		b.WriteByte('[')
+		b.WriteString(drkr.sdID)
+		b.Write(params.Bytes())
+		b.WriteByte(']')
+	}
+
+	if e.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(escapeControl(e.Message))
+	}
+
+	return b.Bytes()
+}
+
+// rfc3164 formats e as a legacy BSD syslog message
+func (drkr *SyslogDrinker) rfc3164(e *Entry) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString(drkr.priority(e.Level))
+	b.WriteString(e.Time.Format("Jan _2 15:04:05"))
+	b.WriteByte(' ')
+	b.WriteString(syslogHeader(drkr.hostname, 255))
+	b.WriteByte(' ')
+	b.WriteString(syslogHeader(drkr.appName, 32))
+	b.WriteString("[" + drkr.pid + "]: ")
+	b.WriteString(escapeControl(e.Message))
+
+	// the standard values after time, level and message are appended as fields
+	for _, field := range e.Standard()[3:] {
+		b.WriteByte(' ')
+		appendLogfmt(b, field.Key, field.Value)
+	}
+	b.Write(e.Fields.Encoded(drkr, drkr.encodeFields))
+
+	return b.Bytes()
+}
+
+// encodeFields encodes fields as structured data parameters, or as logfmt
+// pairs for RFC 3164, each preceded by a space
+func (drkr *SyslogDrinker) encodeFields(fields []Field) []byte {
+	b := new(bytes.Buffer)
+	for _, field := range fields {
+		if drkr.format == RFC3164 {
+			b.WriteByte(' ')
+			appendLogfmt(b, field.Key, field.Value)
+		} else {
+			appendSDParam(b, field.Key, field.Value)
+		}
+	}
+	return b.Bytes()
+}
+
+// appendSDParam appends a space and the structured data parameter name="value"
+func appendSDParam(b *bytes.Buffer, key string, value interface{}) {
+	if list, ok := value.(ErrorList); ok {
+		for i, info := range list {
+			appendSDParam(b, fmt.Sprintf("%s.%d", key, i), info)
+		}
+		return
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(syslogName(key))
+	b.WriteString(`="`)
+
+	for _, r := range escapeControl(fmt.Sprint(value)) {
+		if r == '"' || r == '\\' || r == ']' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	b.WriteByte('"')
+}
+
+// syslogName returns name as a structured data name: at most 32 printable
+// ASCII characters other than '=', ' ', ']' and '"', which are replaced by '_'
+func syslogName(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	b := new(strings.Builder)
+	for _, r := range name {
+		if b.Len() == 32 {
+			break
+		}
+
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// syslogHeader returns value as a header field: at most max printable ASCII
+// characters, with the others replaced by '_', or "-" if empty
+func syslogHeader(value string, max int) string {
+	if value == "" {
+		return "-"
+	}
+
+	b := new(strings.Builder)
+	for _, r := range value {
+		if b.Len() == max {
+			break
+		}
+
+		if r <= ' ' || r > '~' {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
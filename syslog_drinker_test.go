@@ -0,0 +1,249 @@
+/*
+Copyright 2015 Doubledutch
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lager
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSyslogLager(t *testing.T, config *SyslogConfig) (ContextLager, *SyslogDrinker) {
+	config.AppName = "lager"
+	config.Hostname = "hill-valley"
+	if config.SDID == "" {
+		config.SDID = "lager@32473"
+	}
+
+	drinker, err := NewSyslogDrinker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestLager(t, drinker), drinker
+}
+
+func readDatagram(t *testing.T, conn net.PacketConn) string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 64*1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSyslogDrinkerRFC5424(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	config := DefaultSyslogConfig()
+	config.Network = "udp"
+	config.Address = conn.LocalAddr().String()
+	config.Facility = FacilityLocal0
+	logger, _ := newSyslogLager(t, config)
+
+	logger.Set("user", `marty "mcfly"`).Set("bad key=]", "a]b").Warnf("hello\nworld")
+
+	pid := strconv.Itoa(os.Getpid())
+	expected := `<132>1 2015-10-21T16:29:00.123Z hill-valley lager ` + pid +
+		` - [lager@32473 user="marty \"mcfly\"" bad_key__="a\]b"] hello\nworld`
+	if msg := readDatagram(t, conn); msg != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, msg)
+	}
+}
+
+func TestSyslogDrinkerRFC3164(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	config := DefaultSyslogConfig()
+	config.Network = "udp"
+	config.Address = conn.LocalAddr().String()
+	config.Format = RFC3164
+	logger, _ := newSyslogLager(t, config)
+
+	logger.Set("user", "marty mcfly").WithError(errors.New("late")).Errorf("hello")
+
+	expected := `<11>Oct 21 16:29:00 hill-valley lager[` + strconv.Itoa(os.Getpid()) +
+		`]: hello user="marty mcfly" error=late`
+	if msg := readDatagram(t, conn); msg != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, msg)
+	}
+}
+
+func TestSyslogDrinkerSeverities(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	config := DefaultSyslogConfig()
+	config.Network = "udp"
+	config.Address = conn.LocalAddr().String()
+	logger, _ := newSyslogLager(t, config)
+
+	logs := []struct {
+		log func(string, ...interface{})
+		pri string
+	}{
+		{logger.Tracef, "<15>"},
+		{logger.Debugf, "<15>"},
+		{logger.Infof, "<14>"},
+		{logger.Warnf, "<12>"},
+		{logger.Errorf, "<11>"},
+	}
+
+	for _, l := range logs {
+		l.log("hello")
+		if msg := readDatagram(t, conn); msg[:len(l.pri)] != l.pri {
+			t.Fatalf("expected priority %s, got '%s'", l.pri, msg)
+		}
+	}
+}
+
+func TestSyslogDrinkerTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	config := DefaultSyslogConfig()
+	config.Network = "tcp"
+	config.Address = ln.Addr().String()
+	logger, drinker := newSyslogLager(t, config)
+
+	readFrame := func() string {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		r := bufio.NewReader(conn)
+		length, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := strconv.Atoi(length[:len(length)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+		return string(msg)
+	}
+
+	logger.Infof("first")
+	if msg := readFrame(); msg[len(msg)-6:] != " first" {
+		t.Fatalf("expected first message, got '%s'", msg)
+	}
+
+	// logging after the connection is closed reconnects
+	drinker.Close()
+	logger.Infof("second")
+	if msg := readFrame(); msg[len(msg)-7:] != " second" {
+		t.Fatalf("expected second message, got '%s'", msg)
+	}
+}
+
+func TestSyslogDrinkerUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	config := DefaultSyslogConfig()
+	config.Address = path
+	config.SDID = "my id"
+	logger, _ := newSyslogLager(t, config)
+
+	logger.Set("a", "one").Errorf("failed")
+
+	msg := readDatagram(t, conn)
+	expected := `[my_id a="one"] failed`
+	if msg[len(msg)-len(expected):] != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, msg)
+	}
+}
+
+func TestSyslogDrinkerUnixStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	config := DefaultSyslogConfig()
+	config.Address = path
+	logger, _ := newSyslogLager(t, config)
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for _, expected := range []string{"first", "second"} {
+		logger.Infof("%s", expected)
+
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, "<14>1 ") || !strings.HasSuffix(line, " "+expected+"\n") {
+			t.Fatalf("expected a newline terminated message, got '%s'", line)
+		}
+	}
+}
+
+func TestSyslogName(t *testing.T) {
+	names := map[string]string{
+		"":                                      "_",
+		"user":                                  "user",
+		`a b=c]d"e`:                             "a_b_c_d_e",
+		"café":                                  "caf_",
+		"0123456789012345678901234567890123456": "01234567890123456789012345678901",
+	}
+
+	for name, expected := range names {
+		if actual := syslogName(name); actual != expected {
+			t.Fatalf("expected '%s' for '%s', got '%s'", expected, name, actual)
+		}
+	}
+}